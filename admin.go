@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ================================== Admin User Management =======================================
+// ================================================================================================
+// ================================================================================================
+
+const (
+	ROLE_ADMIN    string = "admin"
+	ROLE_GRADUATE string = "graduate"
+	ROLE_EMPLOYER string = "employer"
+)
+
+func isValidRole(role string) bool {
+	return role == ROLE_ADMIN || role == ROLE_GRADUATE || role == ROLE_EMPLOYER
+}
+
+func findUserById(userId int) (User, error) {
+	users := []User{}
+	err := gormDB.Where("id = ?", userId).Limit(1).Find(&users).Error
+	if err != nil {
+		return User{}, err
+	}
+
+	if len(users) == 0 {
+		return User{}, fmt.Errorf("User not found in the system")
+	}
+
+	return users[0], nil
+}
+
+// Grant or remove one role to the user. The role name is also the column name in the 'users' table
+func setUserRole(userId int, role string, value bool) (User, error) {
+	if !isValidRole(role) {
+		return User{}, fmt.Errorf("Unknown role '%s', expected one of: admin, graduate, employer", role)
+	}
+
+	user, err := findUserById(userId)
+	if err != nil {
+		return user, err
+	}
+
+	err = gormDB.Model(&User{}).
+		Where("id = ?", userId).
+		Update(role, value).
+		Error
+	if err != nil {
+		return user, err
+	}
+
+	return findUserById(userId)
+}
+
+func setUserSuspended(userId int, suspended bool) (User, error) {
+	user, err := findUserById(userId)
+	if err != nil {
+		return user, err
+	}
+
+	err = gormDB.Model(&User{}).
+		Where("id = ?", userId).
+		Update("suspended", suspended).
+		Error
+	if err != nil {
+		return user, err
+	}
+
+	if suspended {
+		if err = revokeUserSessions(userId); err != nil {
+			return user, err
+		}
+	}
+
+	user.Suspended = suspended
+	return user, nil
+}
+
+// Remove the user and every row that reference it, otherwise those rows would be left with broken references
+func deleteUser(userId int) error {
+	user, err := findUserById(userId)
+	if err != nil {
+		return err
+	}
+
+	// A company must keep at least one owner, the ownership has to be transferred before
+	ownedCompanyIds := []int{}
+	gormDB.Model(&CompanyMember{}).Where("user_id = ? AND role = ?", userId, COMPANY_OWNER).Pluck("company_id", &ownedCompanyIds)
+
+	for _, companyId := range ownedCompanyIds {
+		if countCompanyOwners(companyId) <= 1 {
+			return fmt.Errorf("This user is the last owner of company %d, transfer the ownership before deleting the account", companyId)
+		}
+	}
+
+	attachments := []Attachment{}
+	gormDB.Where("owner_id = ?", userId).Find(&attachments)
+
+	err = gormDB.Transaction(func(tx *gorm.DB) error {
+		if err := releaseEmployerJobs(tx, userId); err != nil {
+			return err
+		}
+
+		cvIds := []int{}
+		tx.Model(&CurriculumVitae{}).Where("graduate_id = ?", userId).Pluck("id", &cvIds)
+
+		statements := []struct {
+			query string
+			args  []interface{}
+		}{
+			{"DELETE FROM graduate_skills_tree WHERE curriculum_vitae_id IN ?", []interface{}{cvIds}},
+			{"DELETE FROM educations WHERE cv_id IN ?", []interface{}{cvIds}},
+			{"DELETE FROM work_experiences WHERE cv_id IN ?", []interface{}{cvIds}},
+			{"DELETE FROM projects WHERE cv_id IN ?", []interface{}{cvIds}},
+			{"DELETE FROM certifications WHERE cv_id IN ?", []interface{}{cvIds}},
+			{"DELETE FROM spoken_languages WHERE cv_id IN ?", []interface{}{cvIds}},
+			{"DELETE FROM curriculum_vitaes WHERE graduate_id = ?", []interface{}{userId}},
+			{"DELETE FROM cv_snapshots WHERE graduate_id = ?", []interface{}{userId}},
+			{"DELETE FROM application_status_changes WHERE application_id IN (SELECT id FROM job_applications WHERE graduate_id = ?)", []interface{}{userId}},
+			{"DELETE FROM screening_answers WHERE application_id IN (SELECT id FROM job_applications WHERE graduate_id = ?)", []interface{}{userId}},
+			{"DELETE FROM job_applications WHERE graduate_id = ?", []interface{}{userId}},
+			{"DELETE FROM friendships WHERE from_id = ? OR to_id = ?", []interface{}{userId, userId}},
+			{"DELETE FROM messages WHERE sender_id = ? OR receiver_id = ?", []interface{}{userId, userId}},
+			{"DELETE FROM refresh_tokens WHERE user_id = ?", []interface{}{userId}},
+			{"DELETE FROM password_reset_tokens WHERE user_id = ?", []interface{}{userId}},
+			{"DELETE FROM company_invitations WHERE invited_by_id = ? OR email = ?", []interface{}{userId, user.Email}},
+			{"DELETE FROM company_members WHERE user_id = ?", []interface{}{userId}},
+			{"DELETE FROM attachments WHERE owner_id = ?", []interface{}{userId}},
+			{"DELETE FROM users WHERE id = ?", []interface{}{userId}},
+		}
+
+		for _, stmt := range statements {
+			if err := tx.Exec(stmt.query, stmt.args...).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Files are removed once the rows are gone for good, unless another user uploaded the same file
+	attachmentBlobLock.Lock()
+	defer attachmentBlobLock.Unlock()
+
+	for _, attachment := range attachments {
+		if err := deleteUnreferencedBlob(gormDB, attachment.Hash); err != nil {
+			fmt.Println("[Admin] unable to delete file ", attachment.Hash, ": ", err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ================================= Application Pipeline =========================================
+// ================================================================================================
+// ================================================================================================
+//
+//	submitted ─> viewed ─> shortlisted ─> interview ─> offered ─> hired
+//
+// Employers move an application forward (stages can be skipped) or reject it,
+// the graduate can withdraw it until a final decision is made.
+// Every change is appended to 'application_status_changes', rows of this table are never updated nor deleted
+
+const (
+	APPLICATION_SUBMITTED   string = "submitted"
+	APPLICATION_VIEWED      string = "viewed"
+	APPLICATION_SHORTLISTED string = "shortlisted"
+	APPLICATION_INTERVIEW   string = "interview"
+	APPLICATION_OFFERED     string = "offered"
+	APPLICATION_REJECTED    string = "rejected"
+	APPLICATION_WITHDRAWN   string = "withdrawn"
+	APPLICATION_HIRED       string = "hired"
+)
+
+const maxApplicationNoteLength int = 2000
+
+// Stages reachable by the employer from each status. Withdrawal is handled separately, it belongs to the graduate
+var applicationStatusTransitions map[string][]string = map[string][]string{
+	APPLICATION_SUBMITTED:   {APPLICATION_VIEWED, APPLICATION_SHORTLISTED, APPLICATION_INTERVIEW, APPLICATION_REJECTED},
+	APPLICATION_VIEWED:      {APPLICATION_SHORTLISTED, APPLICATION_INTERVIEW, APPLICATION_REJECTED},
+	APPLICATION_SHORTLISTED: {APPLICATION_INTERVIEW, APPLICATION_OFFERED, APPLICATION_REJECTED},
+	APPLICATION_INTERVIEW:   {APPLICATION_OFFERED, APPLICATION_REJECTED},
+	APPLICATION_OFFERED:     {APPLICATION_HIRED, APPLICATION_REJECTED},
+	APPLICATION_REJECTED:    {},
+	APPLICATION_WITHDRAWN:   {},
+	APPLICATION_HIRED:       {},
+}
+
+var errApplicationHistoryImmutable = errors.New("application status history can't be modified")
+
+type ApplicationStatusChange struct {
+	Id            int       `json:"id"`
+	ApplicationId int       `json:"application_id" gorm:"index"`
+	FromStatus    string    `json:"from_status"`
+	ToStatus      string    `json:"to_status"`
+	Note          string    `json:"note"`
+	ChangedById   int       `json:"changed_by_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (ApplicationStatusChange) BeforeUpdate(tx *gorm.DB) error {
+	return errApplicationHistoryImmutable
+}
+
+func (ApplicationStatusChange) BeforeDelete(tx *gorm.DB) error {
+	return errApplicationHistoryImmutable
+}
+
+func isFinalApplicationStatus(status string) bool {
+	return len(applicationStatusTransitions[status]) == 0
+}
+
+func canMoveApplication(from string, to string) bool {
+	for _, status := range applicationStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+func preloadApplicationHistory(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
+
+func findApplicationById(applicationId int) (JobApplication, error) {
+	application := JobApplication{}
+
+	err := gormDB.
+		Preload("Job").
+		Preload("Answers").
+		Preload("History", preloadApplicationHistory).
+		Where("id = ?", applicationId).
+		First(&application).Error
+	if err != nil {
+		return application, fmt.Errorf("Application not found in the system")
+	}
+
+	return application, nil
+}
+
+// Save the new status of the application together with its history entry
+func setApplicationStatus(application JobApplication, status string, note string, changedById int) (JobApplication, error) {
+	if len(note) > maxApplicationNoteLength {
+		return application, fmt.Errorf("Note can't be longer than %d characters", maxApplicationNoteLength)
+	}
+
+	change := ApplicationStatusChange{
+		ApplicationId: application.Id,
+		FromStatus:    application.Status,
+		ToStatus:      status,
+		Note:          note,
+		ChangedById:   changedById,
+	}
+
+	err := gormDB.Transaction(func(tx *gorm.DB) error {
+		// Guard against a concurrent change made since the application was loaded
+		result := tx.Model(&JobApplication{}).
+			Where("id = ? AND status = ?", application.Id, application.Status).
+			Update("status", status)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("The application status changed in the meantime, reload it and try again")
+		}
+
+		return tx.Create(&change).Error
+	})
+	if err != nil {
+		return application, err
+	}
+
+	return findApplicationById(application.Id)
+}
+
+func moveApplication(application JobApplication, status string, note string, changedById int) (JobApplication, error) {
+	if _, ok := applicationStatusTransitions[status]; !ok || status == APPLICATION_WITHDRAWN {
+		return application, fmt.Errorf("Unknown application status '%s', expected one of: viewed, shortlisted, interview, offered, rejected, hired", status)
+	}
+
+	if !canMoveApplication(application.Status, status) {
+		return application, fmt.Errorf("A %s application can't be moved to %s", application.Status, status)
+	}
+
+	application, err := setApplicationStatus(application, status, note, changedById)
+	if err != nil {
+		return application, err
+	}
+
+	notifyApplicationStatus(application, note)
+
+	return application, nil
+}
+
+func withdrawApplication(application JobApplication, note string, graduateId int) (JobApplication, error) {
+	if isFinalApplicationStatus(application.Status) {
+		return application, fmt.Errorf("A %s application can't be withdrawn", application.Status)
+	}
+
+	return setApplicationStatus(application, APPLICATION_WITHDRAWN, note, graduateId)
+}
+
+// The employer opening a new application marks it as viewed
+func markApplicationViewed(application JobApplication, viewerId int) JobApplication {
+	if application.Status != APPLICATION_SUBMITTED {
+		return application
+	}
+
+	viewed, err := setApplicationStatus(application, APPLICATION_VIEWED, "", viewerId)
+	if err != nil {
+		fmt.Println("[Application] unable to mark application as viewed: ", err.Error())
+		return application
+	}
+
+	application.Status = viewed.Status
+	application.History = viewed.History
+
+	return application
+}
+
+func submitApplication(application *JobApplication) error {
+	application.Id = 0
+	application.Status = APPLICATION_SUBMITTED
+	// Only the ids are taken from the request, the graduate and the job are never saved through an application
+	application.Graduate = User{}
+	application.Job = Job{}
+
+	return gormDB.Transaction(func(tx *gorm.DB) error {
+
+		snapshot, err := snapshotCV(tx, application.GraduateId)
+		if err != nil {
+			return err
+		}
+
+		application.CvSnapshotId = 0
+		if snapshot != nil {
+			application.CvSnapshotId = snapshot.Id
+		}
+
+		if err := tx.Omit(clause.Associations).Create(application).Error; err != nil {
+			return err
+		}
+
+		for i := range application.Answers {
+			application.Answers[i].Id = 0
+			application.Answers[i].ApplicationId = application.Id
+		}
+
+		if len(application.Answers) > 0 {
+			if err := tx.Create(&application.Answers).Error; err != nil {
+				return err
+			}
+		}
+
+		change := ApplicationStatusChange{
+			ApplicationId: application.Id,
+			ToStatus:      APPLICATION_SUBMITTED,
+			ChangedById:   application.GraduateId,
+		}
+
+		if err := tx.Create(&change).Error; err != nil {
+			return err
+		}
+
+		application.History = []ApplicationStatusChange{change}
+		return nil
+	})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ======================================== Audit Log =============================================
+// ================================================================================================
+// ================================================================================================
+
+const (
+	AUDIT_ACCOUNT_LOCKED   string = "account_locked"
+	AUDIT_ACCOUNT_UNLOCKED string = "account_unlocked"
+)
+
+type AuditEntry struct {
+	Id        int       `json:"id"`
+	Event     string    `json:"event" gorm:"index"`
+	UserId    int       `json:"user_id" gorm:"index"`
+	Username  string    `json:"username"`
+	IP        string    `json:"ip"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Failing to write an audit entry must not break the action being audited, errors are only logged
+func recordAuditEvent(event string, user User, ip string, details string) {
+	entry := AuditEntry{
+		Event:    event,
+		UserId:   user.Id,
+		Username: user.Username,
+		IP:       ip,
+		Details:  details,
+	}
+
+	if err := gormDB.Create(&entry).Error; err != nil {
+		fmt.Println("[Audit] unable to record event '", event, "': ", err.Error())
+	}
+}
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ==================================== Password Hashing ==========================================
+// ================================================================================================
+// ================================================================================================
+
+const passwordHashCost int = 12
+
+// Hash compared against when the username is unknown, so a failed lookup costs as much as a wrong password
+const dummyPasswordHash string = "$2a$12$LHRqvgul4auzCDfxbbqmCua2/6UGPR8VZ2KyzRbjWG4aGZk3H5qSG"
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordHashCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// Accounts created before passwords were hashed still hold their password in plaintext.
+// Every bcrypt hash starts with its version prefix, so anything else is a legacy row
+func isPasswordHashed(storedPassword string) bool {
+	return strings.HasPrefix(storedPassword, "$2a$") ||
+		strings.HasPrefix(storedPassword, "$2b$") ||
+		strings.HasPrefix(storedPassword, "$2y$")
+}
+
+// Check the password provided by the client against the one stored in DB.
+// 'isLegacy' is true when the stored password is still in plaintext, in which case the caller should re-hash it
+func verifyPassword(storedPassword string, password string) (isMatch bool, isLegacy bool) {
+	if isPasswordHashed(storedPassword) {
+		err := bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(password))
+		return err == nil, false
+	}
+
+	isMatch = subtle.ConstantTimeCompare([]byte(storedPassword), []byte(password)) == 1
+	return isMatch, true
+}
+
+// Replace the plaintext password of a legacy account by its hash.
+// Only called after a successful login, since it is the only moment the server knows the plaintext password is right
+func migrateLegacyPassword(user *User, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	err = gormDB.Model(&User{}).
+		Where("id = ?", user.Id).
+		Update("password", hash).
+		Error
+	if err != nil {
+		return fmt.Errorf("unable to migrate legacy password for user '%d': %w", user.Id, err)
+	}
+
+	user.Password = hash
+	return nil
+}
+
+// ================================================================================================
+// ================================================================================================
+// ================================ Access & Refresh Tokens =======================================
+// ================================================================================================
+// ================================================================================================
+
+const (
+	accessTokenLifetime  time.Duration = 15 * time.Minute
+	refreshTokenLifetime time.Duration = 7 * 24 * time.Hour
+)
+
+var (
+	errRefreshTokenInvalid = errors.New("Invalid refresh token")
+	errRefreshTokenExpired = errors.New("Refresh token expired")
+	errRefreshTokenReused  = errors.New("Refresh token already used, the session has been revoked")
+	errSessionRevoked      = errors.New("Session has been revoked")
+)
+
+type UserClaims struct {
+	jwt.RegisteredClaims
+	Passport  UserPassport `json:"passport"`
+	SessionId string       `json:"sid"`
+}
+
+// Only the hash of the refresh token is stored, the token itself is only known by the client.
+// Every token issued from the same login share the same 'SessionId', so that a logout (or a detected reuse)
+// revokes the whole chain of rotated tokens at once
+type RefreshToken struct {
+	Id        int       `json:"id"`
+	UserId    int       `json:"user_id" gorm:"index"`
+	SessionId string    `json:"session_id" gorm:"index"`
+	TokenHash string    `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Rotated   bool      `json:"rotated" gorm:"default:false"`
+	Revoked   bool      `json:"revoked" gorm:"default:false"`
+	CreatedAt time.Time `json:"created_at"`
+	User      User      `json:"-" gorm:"foreignKey:UserId"`
+}
+
+func generateRandomToken(size int) (string, error) {
+	buffer := make([]byte, size)
+
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func createAccessToken(passport UserPassport, sessionId string) (string, error) {
+	now := time.Now()
+
+	claims := UserClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenLifetime)),
+		},
+		Passport:  passport,
+		SessionId: sessionId,
+	}
+
+	return signToken(claims)
+}
+
+func createRefreshToken(userId int, sessionId string) (string, error) {
+	token, err := generateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	refreshToken := RefreshToken{
+		UserId:    userId,
+		SessionId: sessionId,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(refreshTokenLifetime),
+	}
+
+	if err = gormDB.Create(&refreshToken).Error; err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Start a new session for the user and return its first access & refresh tokens
+func issueTokenPair(passport UserPassport) (accessToken string, refreshToken string, err error) {
+	sessionId, err := generateRandomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err = createRefreshToken(passport.Id, sessionId)
+	if err != nil {
+		return "", "", err
+	}
+
+	accessToken, err = createAccessToken(passport, sessionId)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// Exchange a refresh token for a new pair of tokens. The old refresh token can't be used anymore.
+// Presenting an already rotated token means it has leaked, thus the whole session is revoked
+func rotateRefreshToken(token string) (accessToken string, refreshToken string, passport UserPassport, err error) {
+	tokens := []RefreshToken{}
+	gormDB.Where("token_hash = ?", hashToken(token)).Limit(1).Find(&tokens)
+
+	if len(tokens) == 0 || tokens[0].Revoked {
+		return "", "", passport, errRefreshTokenInvalid
+	}
+
+	current := tokens[0]
+
+	if current.Rotated {
+		if err = revokeSession(current.SessionId); err != nil {
+			fmt.Println("Unable to revoke session after refresh token reuse: ", err.Error())
+		}
+
+		return "", "", passport, errRefreshTokenReused
+	}
+
+	if time.Now().After(current.ExpiresAt) {
+		return "", "", passport, errRefreshTokenExpired
+	}
+
+	// Only one concurrent request can win the rotation, the others are treated as a reuse
+	result := gormDB.Model(&RefreshToken{}).
+		Where("id = ? AND rotated = false", current.Id).
+		Update("rotated", true)
+	if result.Error != nil {
+		return "", "", passport, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return "", "", passport, errRefreshTokenReused
+	}
+
+	// The passport is reloaded from DB, so that role changes are picked up on refresh
+	users := []User{}
+	gormDB.Where("id = ?", current.UserId).Limit(1).Find(&users)
+
+	if len(users) == 0 || users[0].Suspended {
+		return "", "", passport, errRefreshTokenInvalid
+	}
+
+	passport = users[0].UserPassport
+
+	refreshToken, err = createRefreshToken(passport.Id, current.SessionId)
+	if err != nil {
+		return "", "", passport, err
+	}
+
+	accessToken, err = createAccessToken(passport, current.SessionId)
+	if err != nil {
+		return "", "", passport, err
+	}
+
+	return accessToken, refreshToken, passport, nil
+}
+
+func revokeSession(sessionId string) error {
+	return gormDB.Model(&RefreshToken{}).
+		Where("session_id = ?", sessionId).
+		Update("revoked", true).
+		Error
+}
+
+// Log the user out of every device, used when the account is suspended or its privileges are reduced
+func revokeUserSessions(userId int) error {
+	return gormDB.Model(&RefreshToken{}).
+		Where("user_id = ?", userId).
+		Update("revoked", true).
+		Error
+}
+
+func revokeSessionFromRefreshToken(token string) error {
+	tokens := []RefreshToken{}
+	gormDB.Where("token_hash = ?", hashToken(token)).Limit(1).Find(&tokens)
+
+	if len(tokens) == 0 {
+		return errRefreshTokenInvalid
+	}
+
+	return revokeSession(tokens[0].SessionId)
+}
+
+func isSessionRevoked(sessionId string) bool {
+	var count int64
+
+	gormDB.Model(&RefreshToken{}).
+		Where("session_id = ? AND revoked = false", sessionId).
+		Count(&count)
+
+	return count == 0
+}
+
+// ================================================================================================
+// ================================================================================================
+// ================================== Email Verification ==========================================
+// ================================================================================================
+// ================================================================================================
+
+const (
+	emailVerificationLifetime time.Duration = 48 * time.Hour
+	emailVerificationAudience string        = "email-verification"
+)
+
+var (
+	errEmailVerificationInvalid = errors.New("Invalid or expired verification link")
+	errEmailAlreadyVerified     = errors.New("Email address already verified")
+)
+
+// The email is part of the claims, so that a link sent to a previous address can't verify a new one
+type EmailVerificationClaims struct {
+	jwt.RegisteredClaims
+	Email string `json:"email"`
+}
+
+// Login is refused to unverified accounts, unless REQUIRE_EMAIL_VERIFICATION=false in the .env file
+func isEmailVerificationRequired() bool {
+	return env["REQUIRE_EMAIL_VERIFICATION"] != "false"
+}
+
+func createEmailVerificationToken(user User) (string, error) {
+	now := time.Now()
+
+	claims := EmailVerificationClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(user.Id),
+			Audience:  jwt.ClaimStrings{emailVerificationAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(emailVerificationLifetime)),
+		},
+		Email: user.Email,
+	}
+
+	return signToken(claims)
+}
+
+func sendVerificationEmail(user User) error {
+	token, err := createEmailVerificationToken(user)
+	if err != nil {
+		return err
+	}
+
+	data := RegistrationEmailData{
+		Username:       user.Username,
+		Link:           getAppURL() + "/api/v1/verify-email?token=" + url.QueryEscape(token),
+		ExpiresInHours: int(emailVerificationLifetime.Hours()),
+	}
+
+	return sendTemplatedEmail(user.Email, EMAIL_REGISTRATION, data)
+}
+
+// Flip the verified flag of the user the token was issued for.
+// Once verified, the same link is refused, making it a one-time link
+func verifyEmailToken(tokenString string) (User, error) {
+	claims := &EmailVerificationClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, jwtKeyFunc,
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(emailVerificationAudience),
+		jwt.WithValidMethods(getSigningMethodNames()),
+	)
+
+	if err != nil || !token.Valid {
+		return User{}, errEmailVerificationInvalid
+	}
+
+	userId, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return User{}, errEmailVerificationInvalid
+	}
+
+	user, err := findUserById(userId)
+	if err != nil || user.Email != claims.Email {
+		return User{}, errEmailVerificationInvalid
+	}
+
+	if user.EmailVerified {
+		return user, errEmailAlreadyVerified
+	}
+
+	err = gormDB.Model(&User{}).
+		Where("id = ?", user.Id).
+		Update("email_verified", true).
+		Error
+	if err != nil {
+		return user, err
+	}
+
+	user.EmailVerified = true
+	return user, nil
+}
+
+// ================================================================================================
+// ================================================================================================
+// ==================================== Password Reset ============================================
+// ================================================================================================
+// ================================================================================================
+
+const passwordResetLifetime time.Duration = time.Hour
+
+var (
+	errPasswordResetInvalid = errors.New("Invalid or expired password reset token")
+	errPasswordEmpty        = errors.New("Password can't be empty")
+	errPasswordIncorrect    = errors.New("Current password is incorrect")
+)
+
+// Like refresh tokens, only the hash is stored. A token can be consumed once
+type PasswordResetToken struct {
+	Id        int       `json:"id"`
+	UserId    int       `json:"user_id" gorm:"index"`
+	TokenHash string    `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Used      bool      `json:"used" gorm:"default:false"`
+	CreatedAt time.Time `json:"created_at"`
+	User      User      `json:"-" gorm:"foreignKey:UserId"`
+}
+
+func createPasswordResetToken(userId int) (string, error) {
+	token, err := generateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	resetToken := PasswordResetToken{
+		UserId:    userId,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(passwordResetLifetime),
+	}
+
+	if err = gormDB.Create(&resetToken).Error; err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func sendPasswordResetEmail(user User) error {
+	token, err := createPasswordResetToken(user.Id)
+	if err != nil {
+		return err
+	}
+
+	data := PasswordResetEmailData{
+		Username:         user.Username,
+		Link:             getFrontendURL() + "/reset-password?token=" + url.QueryEscape(token),
+		ExpiresInMinutes: int(passwordResetLifetime.Minutes()),
+	}
+
+	return sendTemplatedEmail(user.Email, EMAIL_PASSWORD_RESET, data)
+}
+
+func updateUserPassword(userId int, password string) error {
+	if password == "" {
+		return errPasswordEmpty
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return gormDB.Model(&User{}).
+		Where("id = ?", userId).
+		Update("password", hash).
+		Error
+}
+
+// Consume the reset token and replace the password.
+// Every session of the user is revoked, since whoever knew the old password may still be logged in
+func resetPasswordWithToken(token string, password string) error {
+	if password == "" {
+		return errPasswordEmpty
+	}
+
+	resetTokens := []PasswordResetToken{}
+	gormDB.Where("token_hash = ?", hashToken(token)).Limit(1).Find(&resetTokens)
+
+	if len(resetTokens) == 0 || resetTokens[0].Used || time.Now().After(resetTokens[0].ExpiresAt) {
+		return errPasswordResetInvalid
+	}
+
+	resetToken := resetTokens[0]
+
+	// Guard against the same token being consumed twice by concurrent requests
+	result := gormDB.Model(&PasswordResetToken{}).
+		Where("id = ? AND used = false", resetToken.Id).
+		Update("used", true)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errPasswordResetInvalid
+	}
+
+	if err := updateUserPassword(resetToken.UserId, password); err != nil {
+		return err
+	}
+
+	// Other links sent before this one are now useless
+	err := gormDB.Model(&PasswordResetToken{}).
+		Where("user_id = ? AND used = false", resetToken.UserId).
+		Update("used", true).
+		Error
+	if err != nil {
+		return err
+	}
+
+	return revokeUserSessions(resetToken.UserId)
+}
+
+// Change the password of a logged in user. Other sessions are revoked, the current one is kept
+func changePassword(userId int, currentSessionId string, oldPassword string, newPassword string) error {
+	if newPassword == "" {
+		return errPasswordEmpty
+	}
+
+	user, err := findUserById(userId)
+	if err != nil {
+		return err
+	}
+
+	isMatch, _ := verifyPassword(user.Password, oldPassword)
+	if !isMatch {
+		return errPasswordIncorrect
+	}
+
+	if err = updateUserPassword(userId, newPassword); err != nil {
+		return err
+	}
+
+	return gormDB.Model(&RefreshToken{}).
+		Where("user_id = ? AND session_id <> ?", userId, currentSessionId).
+		Update("revoked", true).
+		Error
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	// The lowest cost keeps the test fast, the cost is part of the hash anyway
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret!"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("unable to hash the password: %s", err.Error())
+	}
+
+	hash2y := "$2y$" + string(hash)[4:]
+
+	tests := []struct {
+		name           string
+		storedPassword string
+		password       string
+		isMatch        bool
+		isLegacy       bool
+	}{
+		{"hashed, right password", string(hash), "s3cret!", true, false},
+		{"hashed, wrong password", string(hash), "s3cret", false, false},
+		{"hashed, empty password", string(hash), "", false, false},
+		{"hashed, the hash itself as password", string(hash), string(hash), false, false},
+		{"$2y$ hash, right password", hash2y, "s3cret!", true, false},
+		{"legacy, right password", "s3cret!", "s3cret!", true, true},
+		{"legacy, wrong password", "s3cret!", "S3cret!", false, true},
+		{"legacy, prefix of the password", "s3cret!", "s3cret", false, true},
+		{"legacy, empty password", "s3cret!", "", false, true},
+	}
+
+	for _, test := range tests {
+		isMatch, isLegacy := verifyPassword(test.storedPassword, test.password)
+
+		if isMatch != test.isMatch || isLegacy != test.isLegacy {
+			t.Errorf("%s: verifyPassword() = (%v, %v), expected (%v, %v)", test.name, isMatch, isLegacy, test.isMatch, test.isLegacy)
+		}
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := hashPassword("s3cret!")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if !isPasswordHashed(hash) {
+		t.Errorf("hash '%s' isn't recognized as a bcrypt hash", hash)
+	}
+
+	if cost, _ := bcrypt.Cost([]byte(hash)); cost != passwordHashCost {
+		t.Errorf("hash cost is %d, expected %d", cost, passwordHashCost)
+	}
+
+	if isMatch, isLegacy := verifyPassword(hash, "s3cret!"); !isMatch || isLegacy {
+		t.Errorf("verifyPassword() = (%v, %v) for a fresh hash, expected (true, false)", isMatch, isLegacy)
+	}
+}
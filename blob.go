@@ -0,0 +1,298 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ===================================== File Storage =============================================
+// ================================================================================================
+// ================================================================================================
+//
+// Uploaded files (resumes, transcripts, portfolio) are stored by the hash of their content,
+// the same file uploaded twice is stored once. The backend is selected at startup from the .env file :
+//
+//	BLOB_STORE=local           # only backend for now
+//	BLOB_DIR=./uploads         # where the local backend stores the files
+//	UPLOAD_MAX_SIZE=10485760   # in bytes, 10 MB by default
+
+type BlobStore interface {
+	// Store the content under 'key'. Storing an existing key is a no-op, keys are content hashes
+	Put(key string, content []byte) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+var errBlobNotFound = errors.New("file not found in the storage")
+
+func newBlobStoreFromEnv(env map[string]string) (BlobStore, error) {
+	switch env["BLOB_STORE"] {
+	case "", "local":
+		dir := env["BLOB_DIR"]
+		if dir == "" {
+			dir = "./uploads"
+		}
+
+		return &LocalBlobStore{Dir: dir}, nil
+	}
+
+	return nil, fmt.Errorf("unknown BLOB_STORE '%s', expected one of: local", env["BLOB_STORE"])
+}
+
+func getUploadMaxSize() int {
+	size, err := strconv.Atoi(env["UPLOAD_MAX_SIZE"])
+	if err != nil || size <= 0 {
+		return 10 * 1024 * 1024
+	}
+
+	return size
+}
+
+// ==================================================
+//                  Local Filesystem
+// ==================================================
+
+type LocalBlobStore struct {
+	Dir string
+}
+
+// Files are spread in sub-directories named after the first 2 characters of the key, to keep directories small
+func (s *LocalBlobStore) path(key string) (string, error) {
+	if len(key) < 3 || strings.ContainsAny(key, `/\.`) {
+		return "", fmt.Errorf("invalid blob key '%s'", key)
+	}
+
+	return filepath.Join(s.Dir, key[:2], key), nil
+}
+
+func (s *LocalBlobStore) Put(key string, content []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	// Written aside then renamed, a reader never sees a partially written file
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalBlobStore) Get(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errBlobNotFound
+	}
+
+	return file, err
+}
+
+func (s *LocalBlobStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// ================================================================================================
+// ================================================================================================
+// ====================================== Attachments =============================================
+// ================================================================================================
+// ================================================================================================
+
+const (
+	ATTACHMENT_RESUME     string = "resume"
+	ATTACHMENT_TRANSCRIPT string = "transcript"
+	ATTACHMENT_PORTFOLIO  string = "portfolio"
+)
+
+const (
+	CONTENT_TYPE_PDF  string = "application/pdf"
+	CONTENT_TYPE_DOCX string = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	CONTENT_TYPE_PNG  string = "image/png"
+	CONTENT_TYPE_JPEG string = "image/jpeg"
+)
+
+var attachmentContentTypes map[string][]string = map[string][]string{
+	ATTACHMENT_RESUME:     {CONTENT_TYPE_PDF, CONTENT_TYPE_DOCX},
+	ATTACHMENT_TRANSCRIPT: {CONTENT_TYPE_PDF, CONTENT_TYPE_DOCX},
+	ATTACHMENT_PORTFOLIO:  {CONTENT_TYPE_PDF, CONTENT_TYPE_DOCX, CONTENT_TYPE_PNG, CONTENT_TYPE_JPEG},
+}
+
+type Attachment struct {
+	Id          int       `json:"id"`
+	OwnerId     int       `json:"owner_id" gorm:"index"`
+	Kind        string    `json:"kind"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int       `json:"size"`
+	Hash        string    `json:"hash" gorm:"index"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// The type is detected from the content, the one announced by the client can't be trusted
+func detectContentType(fileName string, content []byte) string {
+	contentType := http.DetectContentType(content)
+
+	if strings.HasPrefix(contentType, "application/zip") && strings.EqualFold(filepath.Ext(fileName), ".docx") {
+		reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			return contentType
+		}
+
+		for _, file := range reader.File {
+			if file.Name == "word/document.xml" {
+				return CONTENT_TYPE_DOCX
+			}
+		}
+	}
+
+	return strings.TrimSpace(strings.Split(contentType, ";")[0])
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Files are shared between attachments with the same content. Storing a file and removing the last
+// reference to it are serialized, otherwise a file stored right after the reference count could be deleted
+var attachmentBlobLock sync.Mutex
+
+// Save the uploaded file for the graduate. Uploading again a file already attached (same kind) returns the existing attachment
+func storeAttachment(ownerId int, kind string, fileName string, content []byte) (Attachment, error) {
+	attachment := Attachment{}
+
+	allowedTypes, ok := attachmentContentTypes[kind]
+	if !ok {
+		return attachment, fmt.Errorf("Unknown attachment kind '%s', expected one of: resume, transcript, portfolio", kind)
+	}
+
+	if len(content) == 0 {
+		return attachment, fmt.Errorf("The uploaded file is empty")
+	}
+
+	if len(content) > getUploadMaxSize() {
+		return attachment, fmt.Errorf("The uploaded file can't be bigger than %d bytes", getUploadMaxSize())
+	}
+
+	contentType := detectContentType(fileName, content)
+	if !containsString(allowedTypes, contentType) {
+		return attachment, fmt.Errorf("A %s can't be a '%s' file", kind, contentType)
+	}
+
+	hash := hashContent(content)
+
+	attachmentBlobLock.Lock()
+	defer attachmentBlobLock.Unlock()
+
+	existing := []Attachment{}
+	gormDB.Where("owner_id = ? AND kind = ? AND hash = ?", ownerId, kind, hash).Limit(1).Find(&existing)
+
+	if len(existing) > 0 {
+		return existing[0], nil
+	}
+
+	if err := blobStore.Put(hash, content); err != nil {
+		return attachment, err
+	}
+
+	attachment = Attachment{
+		OwnerId:     ownerId,
+		Kind:        kind,
+		FileName:    filepath.Base(fileName),
+		ContentType: contentType,
+		Size:        len(content),
+		Hash:        hash,
+	}
+
+	err := gormDB.Create(&attachment).Error
+	return attachment, err
+}
+
+func findAttachmentById(attachmentId int) (Attachment, error) {
+	attachment := Attachment{}
+
+	err := gormDB.Where("id = ?", attachmentId).First(&attachment).Error
+	if err != nil {
+		return attachment, fmt.Errorf("Attachment not found in the system")
+	}
+
+	return attachment, nil
+}
+
+// The stored file is only removed once no attachment refers to it anymore
+func deleteAttachment(attachment Attachment) error {
+	attachmentBlobLock.Lock()
+	defer attachmentBlobLock.Unlock()
+
+	return gormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", attachment.Id).Delete(&Attachment{}).Error; err != nil {
+			return err
+		}
+
+		return deleteUnreferencedBlob(tx, attachment.Hash)
+	})
+}
+
+// The caller must hold attachmentBlobLock
+func deleteUnreferencedBlob(tx *gorm.DB, hash string) error {
+	var references int64
+	if err := tx.Model(&Attachment{}).Where("hash = ?", hash).Count(&references).Error; err != nil {
+		return err
+	}
+
+	if references > 0 {
+		return nil
+	}
+
+	return blobStore.Delete(hash)
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ================================ Companies & Recruiters ========================================
+// ================================================================================================
+// ================================================================================================
+//
+// A company is managed by several employer accounts. Their membership role decides what they can do :
+//   - owner     : everything, including editing the company and managing its members
+//   - recruiter : post and manage the company jobs, review applications
+//   - viewer    : read-only access to the company jobs and their applications
+
+const (
+	COMPANY_OWNER     string = "owner"
+	COMPANY_RECRUITER string = "recruiter"
+	COMPANY_VIEWER    string = "viewer"
+)
+
+const companyInvitationLifetime time.Duration = 7 * 24 * time.Hour
+
+var errCompanyInvitationInvalid = errors.New("Invalid or expired company invitation")
+
+type Company struct {
+	Id          int             `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Website     string          `json:"website"`
+	Location    string          `json:"location"`
+	Logo        string          `json:"logo"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Members     []CompanyMember `json:"members,omitempty" gorm:"foreignKey:CompanyId"`
+}
+
+func (company Company) isValid() error {
+	if strings.TrimSpace(company.Name) == "" {
+		return fmt.Errorf("Company name is mandatory")
+	}
+
+	for _, link := range []string{company.Website, company.Logo} {
+		if link == "" {
+			continue
+		}
+
+		parsed, err := url.Parse(link)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("'%s' is not a valid http(s) URL", link)
+		}
+	}
+
+	return nil
+}
+
+type CompanyMember struct {
+	Id        int       `json:"id"`
+	CompanyId int       `json:"company_id" gorm:"uniqueIndex:idx_company_member"`
+	UserId    int       `json:"user_id" gorm:"uniqueIndex:idx_company_member"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	User      *User     `json:"user,omitempty" gorm:"foreignKey:UserId"`
+}
+
+// Only the hash of the invitation token is stored, like refresh and password reset tokens
+type CompanyInvitation struct {
+	Id          int        `json:"id"`
+	CompanyId   int        `json:"company_id" gorm:"index"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	InvitedById int        `json:"invited_by_id"`
+	TokenHash   string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Company     Company    `json:"-" gorm:"foreignKey:CompanyId"`
+}
+
+func isValidCompanyRole(role string) bool {
+	return role == COMPANY_OWNER || role == COMPANY_RECRUITER || role == COMPANY_VIEWER
+}
+
+// Return the membership role of the user in the company, or an empty string when not a member
+func getCompanyRole(userId int, companyId int) string {
+	members := []CompanyMember{}
+	gormDB.Where("company_id = ? AND user_id = ?", companyId, userId).Limit(1).Find(&members)
+
+	if len(members) == 0 {
+		return ""
+	}
+
+	return members[0].Role
+}
+
+func hasCompanyRole(passport UserPassport, companyId int, roles ...string) bool {
+	if passport.Admin {
+		return true
+	}
+
+	role := getCompanyRole(passport.Id, companyId)
+
+	for _, allowed := range roles {
+		if role != "" && role == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Companies in which the user is allowed to post jobs
+func getPostingCompanyIds(userId int) []int {
+	companyIds := []int{}
+
+	gormDB.Model(&CompanyMember{}).
+		Where("user_id = ? AND role IN ?", userId, []string{COMPANY_OWNER, COMPANY_RECRUITER}).
+		Pluck("company_id", &companyIds)
+
+	return companyIds
+}
+
+func getMemberCompanyIds(userId int) []int {
+	companyIds := []int{}
+
+	gormDB.Model(&CompanyMember{}).
+		Where("user_id = ?", userId).
+		Pluck("company_id", &companyIds)
+
+	return companyIds
+}
+
+// The route parameter 'param' must be the id of a company where the caller has one of the 'roles'
+func companyRoleMiddleware(param string, roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+
+		companyId, err := strconv.Atoi(c.Params(param))
+		if err != nil || !hasCompanyRole(passport, companyId, roles...) {
+			return forbidden(c, "Forbidden, you don't have the required role in this company")
+		}
+
+		return c.Next()
+	}
+}
+
+func createCompany(company *Company, ownerId int) error {
+	company.Id = 0
+	company.Members = nil
+
+	return gormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(company).Error; err != nil {
+			return err
+		}
+
+		owner := CompanyMember{
+			CompanyId: company.Id,
+			UserId:    ownerId,
+			Role:      COMPANY_OWNER,
+		}
+
+		return tx.Create(&owner).Error
+	})
+}
+
+func findCompanyById(companyId int) (Company, error) {
+	company := Company{}
+
+	err := gormDB.
+		Preload("Members").
+		Preload("Members.User").
+		Where("id = ?", companyId).
+		First(&company).Error
+	if err != nil {
+		return company, fmt.Errorf("Company not found in the system")
+	}
+
+	for key := range company.Members {
+		if company.Members[key].User != nil {
+			company.Members[key].User.hideSensitiveData()
+		}
+	}
+
+	return company, nil
+}
+
+func inviteToCompany(companyId int, inviter User, email string, role string) (CompanyInvitation, error) {
+	invitation := CompanyInvitation{}
+
+	if !isValidCompanyRole(role) {
+		return invitation, fmt.Errorf("Unknown company role '%s', expected one of: owner, recruiter, viewer", role)
+	}
+
+	if !(User{UserCredential: UserCredential{Username: "-", Password: "-", Email: email}}).IsMandatoryFieldFilled() {
+		return invitation, fmt.Errorf("Invalid email address '%s'", email)
+	}
+
+	company, err := findCompanyById(companyId)
+	if err != nil {
+		return invitation, err
+	}
+
+	token, err := generateRandomToken(32)
+	if err != nil {
+		return invitation, err
+	}
+
+	invitation = CompanyInvitation{
+		CompanyId:   companyId,
+		Email:       email,
+		Role:        role,
+		InvitedById: inviter.Id,
+		TokenHash:   hashToken(token),
+		ExpiresAt:   time.Now().Add(companyInvitationLifetime),
+	}
+
+	if err = gormDB.Create(&invitation).Error; err != nil {
+		return invitation, err
+	}
+
+	data := CompanyInvitationEmailData{
+		CompanyName: company.Name,
+		InviterName: inviter.Username,
+		Role:        role,
+		Link:        getFrontendURL() + "/companies/invitations/accept?token=" + url.QueryEscape(token),
+	}
+
+	// The token is only sent by email, without it the invitation can't be accepted
+	if err = sendTemplatedEmail(email, EMAIL_COMPANY_INVITATION, data); err != nil {
+		gormDB.Where("id = ?", invitation.Id).Delete(&CompanyInvitation{})
+		return invitation, err
+	}
+
+	return invitation, nil
+}
+
+// The invitation is bound to an email address, it can only be accepted by an employer account using it
+func acceptCompanyInvitation(token string, user User) (CompanyMember, error) {
+	member := CompanyMember{}
+
+	invitations := []CompanyInvitation{}
+	gormDB.Where("token_hash = ?", hashToken(token)).Limit(1).Find(&invitations)
+
+	if len(invitations) == 0 || invitations[0].AcceptedAt != nil || time.Now().After(invitations[0].ExpiresAt) {
+		return member, errCompanyInvitationInvalid
+	}
+
+	invitation := invitations[0]
+
+	if !strings.EqualFold(invitation.Email, user.Email) {
+		return member, fmt.Errorf("This invitation was sent to another email address")
+	}
+
+	if !user.Employer {
+		return member, fmt.Errorf("Only employer accounts can join a company")
+	}
+
+	// An owner invited again with another role would be demoted, the company must keep at least one owner
+	if getCompanyRole(user.Id, invitation.CompanyId) == COMPANY_OWNER && invitation.Role != COMPANY_OWNER && countCompanyOwners(invitation.CompanyId) <= 1 {
+		return member, fmt.Errorf("The last owner of a company can't be demoted")
+	}
+
+	err := gormDB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		result := tx.Model(&CompanyInvitation{}).
+			Where("id = ? AND accepted_at IS NULL", invitation.Id).
+			Update("accepted_at", &now)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errCompanyInvitationInvalid
+		}
+
+		existing := []CompanyMember{}
+		tx.Where("company_id = ? AND user_id = ?", invitation.CompanyId, user.Id).Limit(1).Find(&existing)
+
+		if len(existing) > 0 {
+			member = existing[0]
+			member.Role = invitation.Role
+			return tx.Model(&CompanyMember{}).Where("id = ?", member.Id).Update("role", invitation.Role).Error
+		}
+
+		member = CompanyMember{
+			CompanyId: invitation.CompanyId,
+			UserId:    user.Id,
+			Role:      invitation.Role,
+		}
+
+		return tx.Create(&member).Error
+	})
+
+	return member, err
+}
+
+func countCompanyOwners(companyId int) int64 {
+	var count int64
+
+	gormDB.Model(&CompanyMember{}).
+		Where("company_id = ? AND role = ?", companyId, COMPANY_OWNER).
+		Count(&count)
+
+	return count
+}
+
+// A company must always keep at least one owner, otherwise nobody could manage it anymore
+func setCompanyMemberRole(companyId int, userId int, role string) (CompanyMember, error) {
+	member := CompanyMember{}
+
+	if !isValidCompanyRole(role) {
+		return member, fmt.Errorf("Unknown company role '%s', expected one of: owner, recruiter, viewer", role)
+	}
+
+	err := gormDB.Where("company_id = ? AND user_id = ?", companyId, userId).First(&member).Error
+	if err != nil {
+		return member, fmt.Errorf("User is not a member of this company")
+	}
+
+	if member.Role == COMPANY_OWNER && role != COMPANY_OWNER && countCompanyOwners(companyId) <= 1 {
+		return member, fmt.Errorf("The last owner of a company can't be demoted")
+	}
+
+	err = gormDB.Model(&CompanyMember{}).Where("id = ?", member.Id).Update("role", role).Error
+	member.Role = role
+
+	return member, err
+}
+
+func removeCompanyMember(companyId int, userId int) error {
+	member := CompanyMember{}
+
+	err := gormDB.Where("company_id = ? AND user_id = ?", companyId, userId).First(&member).Error
+	if err != nil {
+		return fmt.Errorf("User is not a member of this company")
+	}
+
+	if member.Role == COMPANY_OWNER && countCompanyOwners(companyId) <= 1 {
+		return fmt.Errorf("The last owner of a company can't be removed")
+	}
+
+	return gormDB.Where("id = ?", member.Id).Delete(&CompanyMember{}).Error
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ================================================================================================
+// ================================================================================================
+// =================================== Curriculum Vitae ===========================================
+// ================================================================================================
+// ================================================================================================
+//
+// A graduate has a single CV, always found from the token, never from the request body.
+// When applying to a job, a snapshot of the CV is saved with the application : employers review
+// the CV as it was at that time, whatever the graduate changed since then
+
+var errCVAlreadyExists = errors.New("You already have a CV, update it with PUT /cv instead")
+
+type CVSnapshot struct {
+	Id          int             `json:"id"`
+	CvId        int             `json:"cv_id" gorm:"index"`
+	GraduateId  int             `json:"graduate_id" gorm:"index"`
+	Version     int             `json:"version"`
+	ContentHash string          `json:"-" gorm:"index"`
+	Content     json.RawMessage `json:"content"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+func findGraduateCV(graduateId int) (CurriculumVitae, error) {
+	cv := CurriculumVitae{}
+
+	err := gormDB.
+		Preload("JobRole").
+		Scopes(preloadSkills).
+		Scopes(preloadCVSections).
+		Where("graduate_id = ?", graduateId).
+		First(&cv).Error
+	if err != nil {
+		return cv, fmt.Errorf("You don't have a CV yet, create it with POST /cv")
+	}
+
+	return cv, nil
+}
+
+func createCV(cv *CurriculumVitae, graduateId int) error {
+	var count int64
+	gormDB.Model(&CurriculumVitae{}).Where("graduate_id = ?", graduateId).Count(&count)
+
+	if count > 0 {
+		return errCVAlreadyExists
+	}
+
+	cv.Id = 0
+	cv.GraduateId = graduateId
+
+	// Only the CV summary is saved here : skills and sections have their own routes, where they are validated,
+	// and the graduate or the job role are never created nor changed through a CV
+	cv.Graduate = User{}
+	cv.JobRole = JobRole{}
+	cv.Tree, cv.Skills = nil, nil
+	cv.Education, cv.Experience, cv.Projects, cv.Certifications, cv.Languages = nil, nil, nil, nil, nil
+
+	err := gormDB.Omit(clause.Associations).Create(cv).Error
+
+	// The unique index on graduate_id catches a CV created since the count above
+	if isDuplicatedKeyError(err) {
+		return errCVAlreadyExists
+	}
+
+	return err
+}
+
+func isDuplicatedKeyError(err error) bool {
+	translator, ok := gormDB.Dialector.(gorm.ErrorTranslator)
+	return err != nil && ok && errors.Is(translator.Translate(err), gorm.ErrDuplicatedKey)
+}
+
+// Only the CV summary is updated here. Skills and sections have their own routes
+func updateCV(graduateId int, changes CurriculumVitae) (CurriculumVitae, error) {
+	cv, err := findGraduateCV(graduateId)
+	if err != nil {
+		return cv, err
+	}
+
+	err = gormDB.Model(&CurriculumVitae{Id: cv.Id}).
+		Select("Gpa", "Yoe", "JobRoleId").
+		Updates(&changes).Error
+	if err != nil {
+		return cv, err
+	}
+
+	return findGraduateCV(graduateId)
+}
+
+// Snapshots are kept, the applications made with this CV still refer to them
+func deleteCV(cv CurriculumVitae) error {
+	return gormDB.Transaction(func(tx *gorm.DB) error {
+		return deleteCVRows(tx, cv.Id)
+	})
+}
+
+func deleteCVRows(tx *gorm.DB, cvId int) error {
+	statements := []string{
+		"DELETE FROM graduate_skills_tree WHERE curriculum_vitae_id = ?",
+		"DELETE FROM educations WHERE cv_id = ?",
+		"DELETE FROM work_experiences WHERE cv_id = ?",
+		"DELETE FROM projects WHERE cv_id = ?",
+		"DELETE FROM certifications WHERE cv_id = ?",
+		"DELETE FROM spoken_languages WHERE cv_id = ?",
+		"DELETE FROM curriculum_vitaes WHERE id = ?",
+	}
+
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt, cvId).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func removeCVSkill(cvId int, skillId int) error {
+	result := gormDB.Exec("DELETE FROM graduate_skills_tree WHERE curriculum_vitae_id = ? AND job_skill_id = ?", cvId, skillId)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("This skill isn't listed on your CV")
+	}
+
+	return nil
+}
+
+// Save the current state of the graduate CV. A CV unchanged since its last snapshot reuses it,
+// so the version only increases when the CV actually changed. Graduates without CV get no snapshot (nil)
+func snapshotCV(tx *gorm.DB, graduateId int) (*CVSnapshot, error) {
+	cvs := []CurriculumVitae{}
+	err := tx.
+		Preload("JobRole").
+		Scopes(preloadSkills).
+		Scopes(preloadCVSections).
+		Where("graduate_id = ?", graduateId).
+		Limit(1).
+		Find(&cvs).Error
+	if err != nil || len(cvs) == 0 {
+		return nil, err
+	}
+
+	cv := cvs[0]
+
+	content, err := json.Marshal(cv)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := hashContent(content)
+
+	latest := []CVSnapshot{}
+	tx.Where("cv_id = ?", cv.Id).Order("version desc").Limit(1).Find(&latest)
+
+	if len(latest) > 0 && latest[0].ContentHash == hash {
+		return &latest[0], nil
+	}
+
+	snapshot := CVSnapshot{
+		CvId:        cv.Id,
+		GraduateId:  graduateId,
+		Version:     1,
+		ContentHash: hash,
+		Content:     content,
+	}
+
+	if len(latest) > 0 {
+		snapshot.Version = latest[0].Version + 1
+	}
+
+	err = tx.Create(&snapshot).Error
+	return &snapshot, err
+}
+
+// Before one CV per graduate was enforced, a graduate could have several. The latest one is kept
+func migrateDuplicateCVs(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&CurriculumVitae{}) {
+		return nil
+	}
+
+	duplicates := []CurriculumVitae{}
+	err := db.Raw(`
+    SELECT * FROM curriculum_vitaes
+    WHERE id NOT IN (SELECT MAX(id) FROM curriculum_vitaes GROUP BY graduate_id);
+  `).Scan(&duplicates).Error
+	if err != nil {
+		return err
+	}
+
+	for _, cv := range duplicates {
+		if err := db.Transaction(func(tx *gorm.DB) error { return deleteCVRows(tx, cv.Id) }); err != nil {
+			return err
+		}
+
+		fmt.Println("[Migration] duplicate CV ", cv.Id, " of graduate ", cv.GraduateId, " removed")
+	}
+
+	return nil
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ====================================== CV Sections =============================================
+// ================================================================================================
+// ================================================================================================
+//
+// Entries listed on a CV : education, work experience, projects, certifications and spoken languages.
+// Dates are written 'YYYY-MM-DD'. An entry without end date is still ongoing
+
+const (
+	PROFICIENCY_ELEMENTARY   string = "elementary"
+	PROFICIENCY_LIMITED      string = "limited"
+	PROFICIENCY_PROFESSIONAL string = "professional"
+	PROFICIENCY_FULL         string = "full"
+	PROFICIENCY_NATIVE       string = "native"
+)
+
+const cvDateLayout string = "2006-01-02"
+
+type Education struct {
+	Id           int     `json:"id"`
+	CvId         int     `json:"cv_id" gorm:"index"`
+	Institution  string  `json:"institution"`
+	Degree       string  `json:"degree"`
+	FieldOfStudy string  `json:"field_of_study"`
+	StartDate    string  `json:"start_date"`
+	EndDate      string  `json:"end_date"`
+	Gpa          float64 `json:"gpa"`
+	Description  string  `json:"description"`
+}
+
+type WorkExperience struct {
+	Id          int    `json:"id"`
+	CvId        int    `json:"cv_id" gorm:"index"`
+	Company     string `json:"company"`
+	Title       string `json:"title"`
+	Location    string `json:"location"`
+	StartDate   string `json:"start_date"`
+	EndDate     string `json:"end_date"`
+	Description string `json:"description"`
+}
+
+type Project struct {
+	Id          int    `json:"id"`
+	CvId        int    `json:"cv_id" gorm:"index"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Url         string `json:"url"`
+	StartDate   string `json:"start_date"`
+	EndDate     string `json:"end_date"`
+}
+
+type Certification struct {
+	Id            int    `json:"id"`
+	CvId          int    `json:"cv_id" gorm:"index"`
+	Name          string `json:"name"`
+	Issuer        string `json:"issuer"`
+	IssuedAt      string `json:"issued_at"`
+	ExpiresAt     string `json:"expires_at"`
+	CredentialUrl string `json:"credential_url"`
+}
+
+type SpokenLanguage struct {
+	Id          int    `json:"id"`
+	CvId        int    `json:"cv_id" gorm:"index"`
+	Language    string `json:"language"`
+	Proficiency string `json:"proficiency"`
+}
+
+func (e Education) isValid() error {
+	if strings.TrimSpace(e.Institution) == "" || strings.TrimSpace(e.Degree) == "" {
+		return fmt.Errorf("Institution and degree are mandatory for an education entry")
+	}
+
+	if e.Gpa < 0 {
+		return fmt.Errorf("GPA can't be negative")
+	}
+
+	return validateDateRange(e.StartDate, e.EndDate, true)
+}
+
+func (e WorkExperience) isValid() error {
+	if strings.TrimSpace(e.Company) == "" || strings.TrimSpace(e.Title) == "" {
+		return fmt.Errorf("Company and title are mandatory for a work experience")
+	}
+
+	return validateDateRange(e.StartDate, e.EndDate, true)
+}
+
+func (p Project) isValid() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("Project name is mandatory")
+	}
+
+	if err := validateOptionalURL(p.Url); err != nil {
+		return err
+	}
+
+	return validateDateRange(p.StartDate, p.EndDate, false)
+}
+
+func (c Certification) isValid() error {
+	if strings.TrimSpace(c.Name) == "" || strings.TrimSpace(c.Issuer) == "" {
+		return fmt.Errorf("Name and issuer are mandatory for a certification")
+	}
+
+	if err := validateOptionalURL(c.CredentialUrl); err != nil {
+		return err
+	}
+
+	return validateDateRange(c.IssuedAt, c.ExpiresAt, false)
+}
+
+func (l SpokenLanguage) isValid() error {
+	if strings.TrimSpace(l.Language) == "" {
+		return fmt.Errorf("Language is mandatory")
+	}
+
+	switch l.Proficiency {
+	case PROFICIENCY_ELEMENTARY, PROFICIENCY_LIMITED, PROFICIENCY_PROFESSIONAL, PROFICIENCY_FULL, PROFICIENCY_NATIVE:
+		return nil
+	}
+
+	return fmt.Errorf("Unknown proficiency '%s', expected one of: elementary, limited, professional, full, native", l.Proficiency)
+}
+
+func validateDateRange(start string, end string, isStartRequired bool) error {
+	if start == "" {
+		if isStartRequired || end != "" {
+			return fmt.Errorf("Start date is mandatory")
+		}
+
+		return nil
+	}
+
+	startDate, err := time.Parse(cvDateLayout, start)
+	if err != nil {
+		return fmt.Errorf("Invalid date '%s', expected the format YYYY-MM-DD", start)
+	}
+
+	if end == "" {
+		return nil
+	}
+
+	endDate, err := time.Parse(cvDateLayout, end)
+	if err != nil {
+		return fmt.Errorf("Invalid date '%s', expected the format YYYY-MM-DD", end)
+	}
+
+	if endDate.Before(startDate) {
+		return fmt.Errorf("End date can't be before the start date")
+	}
+
+	return nil
+}
+
+func validateOptionalURL(link string) error {
+	if link == "" {
+		return nil
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("'%s' is not a valid http(s) URL", link)
+	}
+
+	return nil
+}
+
+// Id and CV of an entry always come from the route, never from the request body
+func (e *Education) bind(id int, cvId int)      { e.Id, e.CvId = id, cvId }
+func (e *WorkExperience) bind(id int, cvId int) { e.Id, e.CvId = id, cvId }
+func (p *Project) bind(id int, cvId int)        { p.Id, p.CvId = id, cvId }
+func (c *Certification) bind(id int, cvId int)  { c.Id, c.CvId = id, cvId }
+func (l *SpokenLanguage) bind(id int, cvId int) { l.Id, l.CvId = id, cvId }
+
+func preloadCVSections(db *gorm.DB) *gorm.DB {
+	latestFirst := func(db *gorm.DB) *gorm.DB { return db.Order("start_date desc") }
+
+	return db.
+		Preload("Education", latestFirst).
+		Preload("Experience", latestFirst).
+		Preload("Projects", latestFirst).
+		Preload("Certifications", func(db *gorm.DB) *gorm.DB { return db.Order("issued_at desc") }).
+		Preload("Languages")
+}
+
+// The route parameter 'param' must be the id of a CV the caller can access
+func cvOwnerOnlyMiddleware(param string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cvs := []CurriculumVitae{}
+		gormDB.Where("id = ?", c.Params(param)).Limit(1).Find(&cvs)
+
+		if len(cvs) == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "CV not found in the system",
+			})
+		}
+
+		if !canAccessCV(getUserPassportFromMiddlewareContext(c), cvs[0]) {
+			return forbidden(c, "Forbidden, you can only access your own CV")
+		}
+
+		return c.Next()
+	}
+}
+
+type cvSectionEntry[T any] interface {
+	*T
+	isValid() error
+	bind(id int, cvId int)
+}
+
+// Register the list/create/update/delete routes of a CV section under /cv/:cv_id/'section'.
+// The list is answered under the 'section' key, a single entry under the 'entryName' key
+func setupCVSectionRoutes[T any, PT cvSectionEntry[T]](router fiber.Router, section string, entryName string, order string) {
+	path := "/cv/:cv_id<int>/" + section
+	entryPath := path + "/:entry_id<int>"
+	cvOwnerOnly := cvOwnerOnlyMiddleware("cv_id")
+
+	router.Get(path, cvOwnerOnly, func(c *fiber.Ctx) error {
+		entries := []T{}
+
+		err := gormDB.Where("cv_id = ?", c.Params("cv_id")).Order(order).Find(&entries).Error
+		if err != nil {
+			fmt.Println("[GET ", path, "] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			section: entries,
+		})
+	})
+
+	router.Post(path, cvOwnerOnly, func(c *fiber.Ctx) error {
+		cvId, _ := strconv.Atoi(c.Params("cv_id"))
+		var entry PT = new(T)
+
+		if err := c.BodyParser(entry); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		entry.bind(0, cvId)
+
+		if err := entry.isValid(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		if err := gormDB.Create(entry).Error; err != nil {
+			fmt.Println("[POST ", path, "] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			entryName: entry,
+		})
+	})
+
+	router.Put(entryPath, cvOwnerOnly, func(c *fiber.Ctx) error {
+		cvId, _ := strconv.Atoi(c.Params("cv_id"))
+		entryId, _ := strconv.Atoi(c.Params("entry_id"))
+
+		var count int64
+		gormDB.Model(new(T)).Where("id = ? AND cv_id = ?", entryId, cvId).Count(&count)
+
+		if count == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Entry not found in this CV",
+			})
+		}
+
+		var entry PT = new(T)
+
+		if err := c.BodyParser(entry); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		entry.bind(entryId, cvId)
+
+		if err := entry.isValid(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		if err := gormDB.Save(entry).Error; err != nil {
+			fmt.Println("[PUT ", entryPath, "] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			entryName: entry,
+		})
+	})
+
+	router.Delete(entryPath, cvOwnerOnly, func(c *fiber.Ctx) error {
+		result := gormDB.Where("id = ? AND cv_id = ?", c.Params("entry_id"), c.Params("cv_id")).Delete(new(T))
+		if result.Error != nil {
+			fmt.Println("[DELETE ", entryPath, "] ", result.Error.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": result.Error.Error(),
+			})
+		}
+
+		if result.RowsAffected == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Entry not found in this CV",
+			})
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+}
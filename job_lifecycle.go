@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ===================================== Job Lifecycle ============================================
+// ================================================================================================
+// ================================================================================================
+//
+//	draft ──> published <──> paused
+//	  │           │            │
+//	  │           └──> closed <┘
+//	  │                  │  └──> published (reopened)
+//	  └──────────────────┴──> archived
+//
+// Only published jobs are shown to graduates and accept applications.
+// Published and paused jobs are closed automatically once their application deadline passes.
+
+const (
+	JOB_DRAFT     string = "draft"
+	JOB_PUBLISHED string = "published"
+	JOB_PAUSED    string = "paused"
+	JOB_CLOSED    string = "closed"
+	JOB_ARCHIVED  string = "archived"
+)
+
+const jobDeadlineCheckInterval time.Duration = time.Minute
+
+var jobStatusTransitions map[string][]string = map[string][]string{
+	JOB_DRAFT:     {JOB_PUBLISHED, JOB_ARCHIVED},
+	JOB_PUBLISHED: {JOB_PAUSED, JOB_CLOSED},
+	JOB_PAUSED:    {JOB_PUBLISHED, JOB_CLOSED},
+	JOB_CLOSED:    {JOB_PUBLISHED, JOB_ARCHIVED},
+	JOB_ARCHIVED:  {},
+}
+
+func isValidJobStatus(status string) bool {
+	_, ok := jobStatusTransitions[status]
+	return ok
+}
+
+func canTransitionJob(from string, to string) bool {
+	for _, status := range jobStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Move the job to 'status' and record when it happened. The caller saves the job
+func (j *Job) transitionTo(status string) error {
+	if !isValidJobStatus(status) {
+		return fmt.Errorf("Unknown job status '%s', expected one of: draft, published, paused, closed, archived", status)
+	}
+
+	if !canTransitionJob(j.Status, status) {
+		return fmt.Errorf("A %s job can't be moved to %s", j.Status, status)
+	}
+
+	now := time.Now()
+
+	if status == JOB_PUBLISHED && j.ApplicationDeadline != nil && j.ApplicationDeadline.Before(now) {
+		return fmt.Errorf("The application deadline has passed, set a new one before publishing the job again")
+	}
+
+	switch status {
+	case JOB_PUBLISHED:
+		j.PublishedAt = &now
+	case JOB_PAUSED:
+		j.PausedAt = &now
+	case JOB_CLOSED:
+		j.ClosedAt = &now
+	case JOB_ARCHIVED:
+		j.ArchivedAt = &now
+	}
+
+	j.Status = status
+	return nil
+}
+
+func findJobById(jobId int) (Job, error) {
+	job := Job{}
+
+	err := gormDB.
+		Preload("Role").
+		Scopes(preloadSkills).
+		Preload("Questions", preloadScreeningQuestions).
+		Where("id = ?", jobId).
+		First(&job).Error
+	if err != nil {
+		return job, fmt.Errorf("Job not found in the system")
+	}
+
+	return job, nil
+}
+
+// Fields an employer can edit. Ownership (employer, company) and status are never taken from the request body
+var jobEditableColumns []string = []string{
+	"Title", "Description", "Yoe", "RoleId", "SalaryMin", "SalaryMax", "SalaryCurrency",
+	"City", "Country", "WorkplaceType", "ContractType", "ApplicationDeadline",
+}
+
+// Apply 'body' to the job. With 'isPartial' (PATCH), fields missing from the body keep their value,
+// otherwise (PUT) the body replaces every editable field. An optional "status" moves the job through its lifecycle
+func updateJob(job Job, body []byte, isPartial bool) (Job, error) {
+	updated := job
+
+	if !isPartial {
+		updated = Job{}
+	}
+
+	if err := json.Unmarshal(body, &updated); err != nil {
+		return job, err
+	}
+
+	// Restore what can't be edited
+	updated.Id = job.Id
+	updated.EmployerId = job.EmployerId
+	updated.CompanyId = job.CompanyId
+	updated.Tree = job.Tree
+	updated.Skills = job.Skills
+	updated.Role = job.Role
+	updated.Status = job.Status
+	updated.PublishedAt, updated.PausedAt, updated.ClosedAt, updated.ArchivedAt = job.PublishedAt, job.PausedAt, job.ClosedAt, job.ArchivedAt
+	updated.CreatedAt = job.CreatedAt
+
+	// Fields left out of a PUT fall back to the column defaults, as on creation
+	if updated.WorkplaceType == "" {
+		updated.WorkplaceType = WORKPLACE_ON_SITE
+	}
+
+	if updated.ContractType == "" {
+		updated.ContractType = CONTRACT_FULL_TIME
+	}
+
+	request := struct {
+		Status    string               `json:"status"`
+		Questions *[]ScreeningQuestion `json:"questions"`
+	}{}
+	json.Unmarshal(body, &request)
+
+	// Questions are only replaced when sent, even with PUT, since they can't change once graduates applied
+	updated.Questions = job.Questions
+	if request.Questions != nil {
+		updated.Questions = *request.Questions
+	}
+
+	// An unchanged deadline may already be in the past, it mustn't prevent editing the other fields
+	validated := updated
+	if job.ApplicationDeadline != nil && updated.ApplicationDeadline != nil && job.ApplicationDeadline.Equal(*updated.ApplicationDeadline) {
+		validated.ApplicationDeadline = nil
+	}
+
+	// Jobs posted before the location existed have none, it is only required once the location or workplace changes
+	if updated.City == job.City && updated.Country == job.Country && updated.WorkplaceType == job.WorkplaceType {
+		validated.WorkplaceType = WORKPLACE_REMOTE
+	}
+
+	if err := validated.isValid(); err != nil {
+		return job, err
+	}
+
+	if request.Status != "" && request.Status != job.Status {
+		if err := updated.transitionTo(request.Status); err != nil {
+			return job, err
+		}
+	}
+
+	columns := append([]string{"Status", "PublishedAt", "PausedAt", "ClosedAt", "ArchivedAt"}, jobEditableColumns...)
+
+	err := gormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Job{Id: job.Id}).Select(columns).Updates(&updated).Error; err != nil {
+			return err
+		}
+
+		if request.Questions == nil {
+			return nil
+		}
+
+		return replaceScreeningQuestions(tx, job.Id, updated.Questions)
+	})
+	if err != nil {
+		return job, err
+	}
+
+	return findJobById(job.Id)
+}
+
+func setJobStatus(job Job, status string) (Job, error) {
+	if err := job.transitionTo(status); err != nil {
+		return job, err
+	}
+
+	err := gormDB.Model(&Job{Id: job.Id}).
+		Select("Status", "PublishedAt", "PausedAt", "ClosedAt", "ArchivedAt").
+		Updates(&job).Error
+
+	return job, err
+}
+
+// Jobs that already received applications are kept for the graduates history, they can only be archived
+func deleteJob(jobId int) error {
+	var applicationCount int64
+	gormDB.Model(&JobApplication{}).Where("job_id = ?", jobId).Count(&applicationCount)
+
+	if applicationCount > 0 {
+		return fmt.Errorf("This job already received %d application(s), archive it instead", applicationCount)
+	}
+
+	return gormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM job_skills_tree WHERE job_id = ?", jobId).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("job_id = ?", jobId).Delete(&ScreeningQuestion{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("id = ?", jobId).Delete(&Job{}).Error
+	})
+}
+
+// Once an employer is deleted, the jobs it posted outside a company have nobody left to manage them.
+// Those without applications are deleted, the others are archived for the graduates history.
+// Company jobs stay with the company. The employer is cleared from every job, a new user must never inherit them
+func releaseEmployerJobs(tx *gorm.DB, employerId int) error {
+	jobIds := []int{}
+	tx.Model(&Job{}).
+		Where("employer_id = ? AND company_id = 0 AND id NOT IN (SELECT job_id FROM job_applications)", employerId).
+		Pluck("id", &jobIds)
+
+	if err := tx.Exec("DELETE FROM job_skills_tree WHERE job_id IN ?", jobIds).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("job_id IN ?", jobIds).Delete(&ScreeningQuestion{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("id IN ?", jobIds).Delete(&Job{}).Error; err != nil {
+		return err
+	}
+
+	err := tx.Model(&Job{}).
+		Where("employer_id = ? AND company_id = 0 AND status <> ?", employerId, JOB_ARCHIVED).
+		Updates(map[string]interface{}{"status": JOB_ARCHIVED, "archived_at": time.Now()}).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Model(&Job{}).Where("employer_id = ?", employerId).Update("employer_id", 0).Error
+}
+
+func closeExpiredJobs() (int64, error) {
+	now := time.Now()
+
+	result := gormDB.Model(&Job{}).
+		Where("status IN ? AND application_deadline IS NOT NULL AND application_deadline < ?", []string{JOB_PUBLISHED, JOB_PAUSED}, now).
+		Updates(map[string]interface{}{"status": JOB_CLOSED, "closed_at": now})
+
+	return result.RowsAffected, result.Error
+}
+
+func startJobDeadlineWatcher() {
+	go func() {
+		ticker := time.NewTicker(jobDeadlineCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			count, err := closeExpiredJobs()
+			if err != nil {
+				fmt.Println("[Job deadline] unable to close expired jobs: ", err.Error())
+			} else if count > 0 {
+				fmt.Println("[Job deadline] ", count, " job(s) closed, their application deadline passed")
+			}
+
+			<-ticker.C
+		}
+	}()
+}
+
+// Before the lifecycle, a job was either recruiting or not. Not recruiting jobs become closed ones
+func migrateJobRecruitingFlag(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&Job{}, "is_recruiting") {
+		return nil
+	}
+
+	err := db.Model(&Job{}).
+		Where("is_recruiting = false").
+		Updates(map[string]interface{}{"status": JOB_CLOSED, "closed_at": time.Now()}).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Migrator().DropColumn(&Job{}, "is_recruiting")
+}
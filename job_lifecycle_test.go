@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCanTransitionJob(t *testing.T) {
+	tests := []struct {
+		from     string
+		to       string
+		expected bool
+	}{
+		{JOB_DRAFT, JOB_PUBLISHED, true},
+		{JOB_DRAFT, JOB_ARCHIVED, true},
+		{JOB_DRAFT, JOB_PAUSED, false},
+		{JOB_DRAFT, JOB_CLOSED, false},
+		{JOB_PUBLISHED, JOB_PAUSED, true},
+		{JOB_PUBLISHED, JOB_CLOSED, true},
+		{JOB_PUBLISHED, JOB_DRAFT, false},
+		{JOB_PUBLISHED, JOB_ARCHIVED, false},
+		{JOB_PAUSED, JOB_PUBLISHED, true},
+		{JOB_PAUSED, JOB_CLOSED, true},
+		{JOB_PAUSED, JOB_ARCHIVED, false},
+		{JOB_CLOSED, JOB_PUBLISHED, true},
+		{JOB_CLOSED, JOB_ARCHIVED, true},
+		{JOB_CLOSED, JOB_PAUSED, false},
+		{JOB_ARCHIVED, JOB_PUBLISHED, false},
+		{JOB_ARCHIVED, JOB_DRAFT, false},
+		{JOB_PUBLISHED, JOB_PUBLISHED, false},
+		{"", JOB_PUBLISHED, false},
+		{JOB_PUBLISHED, "deleted", false},
+	}
+
+	for _, test := range tests {
+		if got := canTransitionJob(test.from, test.to); got != test.expected {
+			t.Errorf("canTransitionJob(%q, %q) = %v, expected %v", test.from, test.to, got, test.expected)
+		}
+	}
+}
+
+func TestJobTransitionTo(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		job       Job
+		status    string
+		expectErr bool
+	}{
+		{"publish a draft", Job{Status: JOB_DRAFT}, JOB_PUBLISHED, false},
+		{"pause a published job", Job{Status: JOB_PUBLISHED}, JOB_PAUSED, false},
+		{"close a paused job", Job{Status: JOB_PAUSED}, JOB_CLOSED, false},
+		{"archive a closed job", Job{Status: JOB_CLOSED}, JOB_ARCHIVED, false},
+		{"reopen with a future deadline", Job{Status: JOB_CLOSED, ApplicationDeadline: &future}, JOB_PUBLISHED, false},
+		{"reopen with a past deadline", Job{Status: JOB_CLOSED, ApplicationDeadline: &past}, JOB_PUBLISHED, true},
+		{"close with a past deadline", Job{Status: JOB_PUBLISHED, ApplicationDeadline: &past}, JOB_CLOSED, false},
+		{"unknown status", Job{Status: JOB_PUBLISHED}, "deleted", true},
+		{"forbidden transition", Job{Status: JOB_ARCHIVED}, JOB_PUBLISHED, true},
+	}
+
+	for _, test := range tests {
+		job := test.job
+		err := job.transitionTo(test.status)
+
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+
+			if job.Status != test.job.Status {
+				t.Errorf("%s: status changed to %q despite the error", test.name, job.Status)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err.Error())
+			continue
+		}
+
+		if job.Status != test.status {
+			t.Errorf("%s: status is %q, expected %q", test.name, job.Status, test.status)
+		}
+
+		timestamps := map[string]*time.Time{
+			JOB_PUBLISHED: job.PublishedAt,
+			JOB_PAUSED:    job.PausedAt,
+			JOB_CLOSED:    job.ClosedAt,
+			JOB_ARCHIVED:  job.ArchivedAt,
+		}
+
+		if timestamps[test.status] == nil {
+			t.Errorf("%s: no timestamp recorded for %q", test.name, test.status)
+		}
+	}
+}
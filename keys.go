@@ -0,0 +1,291 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ================================== JWT Signing Keys ============================================
+// ================================================================================================
+// ================================================================================================
+//
+// Keys are read from the .env file :
+//
+//	JWT_KEY_IDS=2024_01,2024_06          # every key accepted when verifying a token
+//	JWT_ACTIVE_KEY_ID=2024_06            # key used to sign new tokens
+//	JWT_KEY_2024_01_ALG=HS256            # HS256 (default), RS256 or EdDSA
+//	JWT_KEY_2024_01_SECRET=...           # HS256 only
+//	JWT_KEY_2024_06_ALG=RS256
+//	JWT_KEY_2024_06_PRIVATE_KEY=./keys/2024_06.pem   # PEM file, RS256 & EdDSA
+//	JWT_KEY_2024_06_PUBLIC_KEY=./keys/2024_06.pub    # optional, derived from the private key otherwise
+//
+// Key ids are part of variable names, they can only contain letters, digits and '_'.
+// Rotating a key is done by adding the new one to JWT_KEY_IDS, making it active,
+// then removing the old one once every token it signed has expired.
+// A key with only a public key is verify-only, it can't be the active one
+
+type SigningKey struct {
+	Id        string
+	Method    jwt.SigningMethod
+	SignKey   interface{}
+	VerifyKey interface{}
+}
+
+type JWK map[string]string
+
+var (
+	signingKeys        map[string]SigningKey = map[string]SigningKey{}
+	activeSigningKeyId string
+	jwtKeyIdPattern    *regexp.Regexp = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+func loadSigningKeys(env map[string]string) error {
+	keys := map[string]SigningKey{}
+	keyIds := []string{}
+
+	for _, id := range strings.Split(env["JWT_KEY_IDS"], ",") {
+		id = strings.TrimSpace(id)
+		if id != "" {
+			keyIds = append(keyIds, id)
+		}
+	}
+
+	for _, id := range keyIds {
+		if !jwtKeyIdPattern.MatchString(id) {
+			return fmt.Errorf("JWT key id '%s' can only contain letters, digits and '_'", id)
+		}
+
+		key, err := loadSigningKey(env, id)
+		if err != nil {
+			return fmt.Errorf("JWT key '%s': %w", id, err)
+		}
+
+		keys[id] = key
+	}
+
+	activeId := env["JWT_ACTIVE_KEY_ID"]
+
+	// Fallback for setups with a single shared secret and no key rotation
+	if len(keys) == 0 && env["JWT_SECRET"] != "" {
+		keys["default"] = SigningKey{
+			Id:        "default",
+			Method:    jwt.SigningMethodHS256,
+			SignKey:   []byte(env["JWT_SECRET"]),
+			VerifyKey: []byte(env["JWT_SECRET"]),
+		}
+		activeId = "default"
+	}
+
+	if len(keys) == 0 {
+		secret, err := generateRandomToken(32)
+		if err != nil {
+			return err
+		}
+
+		log.Println("[Warning] No JWT signing key configured, using a random one. Every token will be invalidated on restart")
+
+		keys["ephemeral"] = SigningKey{
+			Id:        "ephemeral",
+			Method:    jwt.SigningMethodHS256,
+			SignKey:   []byte(secret),
+			VerifyKey: []byte(secret),
+		}
+		activeId = "ephemeral"
+	}
+
+	if activeId == "" && len(keyIds) == 1 {
+		activeId = keyIds[0]
+	}
+
+	active, ok := keys[activeId]
+	if !ok {
+		return fmt.Errorf("active JWT key '%s' is not part of JWT_KEY_IDS", activeId)
+	}
+
+	if active.SignKey == nil {
+		return fmt.Errorf("active JWT key '%s' has no private key to sign with", activeId)
+	}
+
+	signingKeys = keys
+	activeSigningKeyId = activeId
+
+	return nil
+}
+
+func loadSigningKey(env map[string]string, id string) (SigningKey, error) {
+	prefix := "JWT_KEY_" + id + "_"
+	key := SigningKey{Id: id}
+
+	alg := env[prefix+"ALG"]
+	if alg == "" {
+		alg = jwt.SigningMethodHS256.Alg()
+	}
+
+	switch alg {
+	case jwt.SigningMethodHS256.Alg():
+		secret := env[prefix+"SECRET"]
+		if secret == "" {
+			return key, fmt.Errorf("%sSECRET is mandatory for HS256", prefix)
+		}
+
+		key.Method = jwt.SigningMethodHS256
+		key.SignKey = []byte(secret)
+		key.VerifyKey = []byte(secret)
+
+	case jwt.SigningMethodRS256.Alg():
+		key.Method = jwt.SigningMethodRS256
+
+		if path := env[prefix+"PRIVATE_KEY"]; path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return key, err
+			}
+
+			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return key, err
+			}
+
+			key.SignKey = privateKey
+			key.VerifyKey = &privateKey.PublicKey
+		}
+
+		if path := env[prefix+"PUBLIC_KEY"]; path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return key, err
+			}
+
+			publicKey, err := jwt.ParseRSAPublicKeyFromPEM(data)
+			if err != nil {
+				return key, err
+			}
+
+			key.VerifyKey = publicKey
+		}
+
+	case jwt.SigningMethodEdDSA.Alg():
+		key.Method = jwt.SigningMethodEdDSA
+
+		if path := env[prefix+"PRIVATE_KEY"]; path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return key, err
+			}
+
+			privateKey, err := jwt.ParseEdPrivateKeyFromPEM(data)
+			if err != nil {
+				return key, err
+			}
+
+			key.SignKey = privateKey
+			key.VerifyKey = privateKey.(crypto.Signer).Public()
+		}
+
+		if path := env[prefix+"PUBLIC_KEY"]; path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return key, err
+			}
+
+			publicKey, err := jwt.ParseEdPublicKeyFromPEM(data)
+			if err != nil {
+				return key, err
+			}
+
+			key.VerifyKey = publicKey
+		}
+
+	default:
+		return key, fmt.Errorf("unsupported signing algorithm '%s'", alg)
+	}
+
+	if key.VerifyKey == nil {
+		return key, fmt.Errorf("%sPRIVATE_KEY or %sPUBLIC_KEY is mandatory for %s", prefix, prefix, alg)
+	}
+
+	return key, nil
+}
+
+func signToken(claims jwt.Claims) (string, error) {
+	key, ok := signingKeys[activeSigningKeyId]
+	if !ok {
+		return "", fmt.Errorf("no active JWT signing key")
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.Id
+
+	return token.SignedString(key.SignKey)
+}
+
+// Used by 'jwt.Parse' to select the verification key from the 'kid' header of the token
+func jwtKeyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := signingKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key '%s'", kid)
+	}
+
+	// Never let the token choose its own algorithm, otherwise a public key could be used as an HMAC secret
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method '%s' for key '%s'", token.Method.Alg(), kid)
+	}
+
+	return key.VerifyKey, nil
+}
+
+// Public keys in JWK format (RFC 7517), so that other services can verify the tokens on their own.
+// HMAC keys are secret, so they are never part of the set
+func getPublicJWKS() []JWK {
+	jwks := []JWK{}
+
+	for _, key := range signingKeys {
+		switch publicKey := key.VerifyKey.(type) {
+		case *rsa.PublicKey:
+			jwks = append(jwks, JWK{
+				"kty": "RSA",
+				"kid": key.Id,
+				"alg": key.Method.Alg(),
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+			})
+
+		case ed25519.PublicKey:
+			jwks = append(jwks, JWK{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"kid": key.Id,
+				"alg": key.Method.Alg(),
+				"use": "sig",
+				"x":   base64.RawURLEncoding.EncodeToString(publicKey),
+			})
+		}
+	}
+
+	return jwks
+}
+
+func getSigningMethodNames() []string {
+	names := []string{}
+
+	for _, key := range signingKeys {
+		names = append(names, key.Method.Alg())
+	}
+
+	return names
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Replace the loaded keys by an HS256 key and an EdDSA key, for the duration of the test
+func setupTestSigningKeys(t *testing.T) ed25519.PublicKey {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate the EdDSA key: %s", err.Error())
+	}
+
+	previousKeys, previousActiveKeyId := signingKeys, activeSigningKeyId
+
+	signingKeys = map[string]SigningKey{
+		"hs": {Id: "hs", Method: jwt.SigningMethodHS256, SignKey: []byte("test secret"), VerifyKey: []byte("test secret")},
+		"ed": {Id: "ed", Method: jwt.SigningMethodEdDSA, SignKey: privateKey, VerifyKey: publicKey},
+	}
+	activeSigningKeyId = "ed"
+
+	t.Cleanup(func() {
+		signingKeys, activeSigningKeyId = previousKeys, previousActiveKeyId
+	})
+
+	return publicKey
+}
+
+func TestJwtKeyFunc(t *testing.T) {
+	setupTestSigningKeys(t)
+
+	tests := []struct {
+		name      string
+		header    map[string]interface{}
+		method    jwt.SigningMethod
+		expectErr bool
+	}{
+		{"HS256 key", map[string]interface{}{"kid": "hs"}, jwt.SigningMethodHS256, false},
+		{"EdDSA key", map[string]interface{}{"kid": "ed"}, jwt.SigningMethodEdDSA, false},
+		{"missing kid", map[string]interface{}{}, jwt.SigningMethodHS256, true},
+		{"unknown kid", map[string]interface{}{"kid": "old"}, jwt.SigningMethodHS256, true},
+		{"kid isn't a string", map[string]interface{}{"kid": 42}, jwt.SigningMethodHS256, true},
+		{"HS256 with the EdDSA key", map[string]interface{}{"kid": "ed"}, jwt.SigningMethodHS256, true},
+		{"EdDSA with the HS256 key", map[string]interface{}{"kid": "hs"}, jwt.SigningMethodEdDSA, true},
+		{"HS512 with the HS256 key", map[string]interface{}{"kid": "hs"}, jwt.SigningMethodHS512, true},
+		{"none", map[string]interface{}{"kid": "hs"}, jwt.SigningMethodNone, true},
+	}
+
+	for _, test := range tests {
+		key, err := jwtKeyFunc(&jwt.Token{Header: test.header, Method: test.method})
+
+		if test.expectErr {
+			if err == nil || key != nil {
+				t.Errorf("%s: expected the token to be rejected", test.name)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err.Error())
+			continue
+		}
+
+		kid := test.header["kid"].(string)
+		if _, isBytes := key.([]byte); isBytes != (kid == "hs") {
+			t.Errorf("%s: returned the wrong verification key", test.name)
+		}
+	}
+}
+
+func TestJwtKeyFuncParse(t *testing.T) {
+	publicKey := setupTestSigningKeys(t)
+
+	claims := jwt.RegisteredClaims{Subject: "1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+
+	signed, err := signToken(claims)
+	if err != nil {
+		t.Fatalf("unable to sign the token: %s", err.Error())
+	}
+
+	if _, err := jwt.Parse(signed, jwtKeyFunc); err != nil {
+		t.Errorf("token signed with the active key is rejected: %s", err.Error())
+	}
+
+	// Algorithm confusion : an HMAC token signed with the public key, claiming the EdDSA key id
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = "ed"
+
+	forgedString, err := forged.SignedString([]byte(publicKey))
+	if err != nil {
+		t.Fatalf("unable to sign the forged token: %s", err.Error())
+	}
+
+	if _, err := jwt.Parse(forgedString, jwtKeyFunc); err == nil {
+		t.Errorf("HMAC token signed with the public key is accepted")
+	}
+}
+
+// Write the private and public keys as PEM files, return their paths
+func writeTestKeyFiles(t *testing.T, name string, privateKey interface{}, publicKey interface{}) (string, string) {
+	privateBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("unable to encode the %s private key: %s", name, err.Error())
+	}
+
+	publicBytes, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("unable to encode the %s public key: %s", name, err.Error())
+	}
+
+	privatePath := filepath.Join(t.TempDir(), name+".pem")
+	publicPath := filepath.Join(t.TempDir(), name+".pub")
+
+	err = os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateBytes}), 0600)
+	if err == nil {
+		err = os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicBytes}), 0600)
+	}
+
+	if err != nil {
+		t.Fatalf("unable to write the %s key files: %s", name, err.Error())
+	}
+
+	return privatePath, publicPath
+}
+
+func TestLoadSigningKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate the RSA key: %s", err.Error())
+	}
+
+	edPublicKey, edPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate the EdDSA key: %s", err.Error())
+	}
+
+	rsaPrivatePath, rsaPublicPath := writeTestKeyFiles(t, "rsa", rsaKey, &rsaKey.PublicKey)
+	edPrivatePath, edPublicPath := writeTestKeyFiles(t, "ed", edPrivateKey, edPublicKey)
+
+	previousKeys, previousActiveKeyId := signingKeys, activeSigningKeyId
+	t.Cleanup(func() {
+		signingKeys, activeSigningKeyId = previousKeys, previousActiveKeyId
+	})
+
+	tests := []struct {
+		name      string
+		env       map[string]string
+		activeId  string
+		alg       string
+		expectErr bool
+	}{
+		{
+			name:     "HS256",
+			env:      map[string]string{"JWT_KEY_IDS": "2024_01", "JWT_KEY_2024_01_SECRET": "secret"},
+			activeId: "2024_01",
+			alg:      "HS256",
+		},
+		{
+			name:     "RS256 from PEM files",
+			env:      map[string]string{"JWT_KEY_IDS": "rsa", "JWT_KEY_rsa_ALG": "RS256", "JWT_KEY_rsa_PRIVATE_KEY": rsaPrivatePath, "JWT_KEY_rsa_PUBLIC_KEY": rsaPublicPath},
+			activeId: "rsa",
+			alg:      "RS256",
+		},
+		{
+			name:     "EdDSA from a private PEM file",
+			env:      map[string]string{"JWT_KEY_IDS": "ed", "JWT_KEY_ed_ALG": "EdDSA", "JWT_KEY_ed_PRIVATE_KEY": edPrivatePath},
+			activeId: "ed",
+			alg:      "EdDSA",
+		},
+		{
+			name: "verify-only key next to the active one",
+			env: map[string]string{
+				"JWT_KEY_IDS": "old,new", "JWT_ACTIVE_KEY_ID": "new",
+				"JWT_KEY_old_ALG": "EdDSA", "JWT_KEY_old_PUBLIC_KEY": edPublicPath,
+				"JWT_KEY_new_ALG": "RS256", "JWT_KEY_new_PRIVATE_KEY": rsaPrivatePath,
+			},
+			activeId: "new",
+			alg:      "RS256",
+		},
+		{
+			name:     "single shared secret",
+			env:      map[string]string{"JWT_SECRET": "secret"},
+			activeId: "default",
+			alg:      "HS256",
+		},
+		{
+			name:      "missing secret",
+			env:       map[string]string{"JWT_KEY_IDS": "hs"},
+			expectErr: true,
+		},
+		{
+			name:      "missing key file",
+			env:       map[string]string{"JWT_KEY_IDS": "rsa", "JWT_KEY_rsa_ALG": "RS256", "JWT_KEY_rsa_PRIVATE_KEY": filepath.Join(t.TempDir(), "missing.pem")},
+			expectErr: true,
+		},
+		{
+			name:      "no key file",
+			env:       map[string]string{"JWT_KEY_IDS": "ed", "JWT_KEY_ed_ALG": "EdDSA"},
+			expectErr: true,
+		},
+		{
+			name:      "unknown active id",
+			env:       map[string]string{"JWT_KEY_IDS": "hs", "JWT_KEY_hs_SECRET": "secret", "JWT_ACTIVE_KEY_ID": "other"},
+			expectErr: true,
+		},
+		{
+			name:      "verify-only active key",
+			env:       map[string]string{"JWT_KEY_IDS": "ed", "JWT_KEY_ed_ALG": "EdDSA", "JWT_KEY_ed_PUBLIC_KEY": edPublicPath},
+			expectErr: true,
+		},
+		{
+			name:      "unsupported algorithm",
+			env:       map[string]string{"JWT_KEY_IDS": "hs", "JWT_KEY_hs_ALG": "HS512", "JWT_KEY_hs_SECRET": "secret"},
+			expectErr: true,
+		},
+		{
+			name:      "key id that can't be a variable name",
+			env:       map[string]string{"JWT_KEY_IDS": "2024-01", "JWT_KEY_2024-01_SECRET": "secret"},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		signingKeys, activeSigningKeyId = map[string]SigningKey{}, ""
+
+		err := loadSigningKeys(test.env)
+
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+
+			if len(signingKeys) != 0 || activeSigningKeyId != "" {
+				t.Errorf("%s: keys were loaded despite the error", test.name)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err.Error())
+			continue
+		}
+
+		if activeSigningKeyId != test.activeId || signingKeys[activeSigningKeyId].Method.Alg() != test.alg {
+			t.Errorf("%s: active key is '%s' (%s), expected '%s' (%s)", test.name, activeSigningKeyId, signingKeys[activeSigningKeyId].Method.Alg(), test.activeId, test.alg)
+			continue
+		}
+
+		signed, err := signToken(jwt.RegisteredClaims{Subject: "1"})
+		if err == nil {
+			_, err = jwt.Parse(signed, jwtKeyFunc)
+		}
+
+		if err != nil {
+			t.Errorf("%s: unable to sign and verify a token: %s", test.name, err.Error())
+		}
+	}
+}
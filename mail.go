@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ======================================== Mailer ================================================
+// ================================================================================================
+// ================================================================================================
+//
+// The backend is selected at startup from the .env file :
+//
+//	MAILER=smtp | file | memory    # default to 'smtp' when an SMTP account is configured, 'file' otherwise
+//
+//	SMTP_HOST=smtp.gmail.com       # default values are those of Gmail
+//	SMTP_PORT=587
+//	SMTP_TLS=starttls              # starttls, tls (implicit, usually port 465) or none
+//	SMTP_USERNAME=...              # fallback to GMAIL_ACCOUNT
+//	SMTP_PASSWORD=...              # fallback to GMAIL_PASSWORD
+//	SMTP_FROM=...                  # fallback to SMTP_USERNAME
+//
+//	MAIL_SPOOL_DIR=./mail_spool    # 'file' backend only, one .eml file per email
+
+// 'Body' is the plain text version. When 'HTMLBody' is set, both are sent as a multipart/alternative email
+type MailMessage struct {
+	From     string
+	To       []string
+	Subject  string
+	Body     string
+	HTMLBody string
+}
+
+// Retrying won't fix an email that can't even be built
+var errMalformedEmail = errors.New("malformed email")
+
+type Mailer interface {
+	Send(message MailMessage) error
+}
+
+func newMailerFromEnv(env map[string]string) (Mailer, error) {
+	username := env["SMTP_USERNAME"]
+	if username == "" {
+		username = env["GMAIL_ACCOUNT"]
+	}
+
+	password := env["SMTP_PASSWORD"]
+	if password == "" {
+		password = env["GMAIL_PASSWORD"]
+	}
+
+	from := env["SMTP_FROM"]
+	if from == "" {
+		from = username
+	}
+
+	backend := env["MAILER"]
+	if backend == "" {
+		backend = "file"
+
+		if env["SMTP_HOST"] != "" || username != "" {
+			backend = "smtp"
+		}
+	}
+
+	switch backend {
+	case "smtp":
+		mailer := &SMTPMailer{
+			Host:     env["SMTP_HOST"],
+			Port:     env["SMTP_PORT"],
+			TLSMode:  env["SMTP_TLS"],
+			Username: username,
+			Password: password,
+			From:     from,
+		}
+
+		if mailer.Host == "" {
+			mailer.Host = "smtp.gmail.com"
+		}
+
+		if mailer.Port == "" {
+			mailer.Port = "587"
+		}
+
+		if mailer.TLSMode == "" {
+			mailer.TLSMode = "starttls"
+		}
+
+		if mailer.TLSMode != "starttls" && mailer.TLSMode != "tls" && mailer.TLSMode != "none" {
+			return nil, fmt.Errorf("unknown SMTP_TLS mode '%s', expected one of: starttls, tls, none", mailer.TLSMode)
+		}
+
+		return mailer, nil
+
+	case "file":
+		directory := env["MAIL_SPOOL_DIR"]
+		if directory == "" {
+			directory = "./mail_spool"
+		}
+
+		if from == "" {
+			from = "no-reply@localhost"
+		}
+
+		return &FileSpoolMailer{Directory: directory, From: from}, nil
+
+	case "memory":
+		return &MemoryMailer{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown MAILER backend '%s', expected one of: smtp, file, memory", backend)
+}
+
+// Build the raw RFC 5322 message. Header values are encoded, so that non-ASCII subjects and names survive the trip
+func buildRawEmail(message MailMessage) ([]byte, error) {
+	if message.From == "" || len(message.To) == 0 {
+		return nil, fmt.Errorf("%w: sender and receiver are mandatory", errMalformedEmail)
+	}
+
+	to := []string{}
+	for _, address := range message.To {
+		parsed, err := mail.ParseAddress(address)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid receiver address '%s': %s", errMalformedEmail, address, err.Error())
+		}
+
+		to = append(to, parsed.String())
+	}
+
+	from, err := mail.ParseAddress(message.From)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid sender address '%s': %s", errMalformedEmail, message.From, err.Error())
+	}
+
+	messageId, err := generateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	domain := "localhost"
+	if index := strings.LastIndex(from.Address, "@"); index >= 0 {
+		domain = from.Address[index+1:]
+	}
+
+	buffer := bytes.Buffer{}
+	buffer.WriteString("From: " + from.String() + "\r\n")
+	buffer.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	buffer.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", message.Subject) + "\r\n")
+	buffer.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	buffer.WriteString("Message-ID: <" + messageId + "@" + domain + ">\r\n")
+	buffer.WriteString("MIME-Version: 1.0\r\n")
+
+	if message.HTMLBody == "" {
+		buffer.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buffer.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+		buffer.WriteString("\r\n")
+
+		if err = writeQuotedPrintable(&buffer, message.Body); err != nil {
+			return nil, err
+		}
+
+		return buffer.Bytes(), nil
+	}
+
+	// Parts are ordered from the simplest to the richest, mail clients display the last one they support
+	writer := multipart.NewWriter(&buffer)
+
+	buffer.WriteString("Content-Type: multipart/alternative; boundary=\"" + writer.Boundary() + "\"\r\n")
+	buffer.WriteString("\r\n")
+
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", message.Body},
+		{"text/html; charset=utf-8", message.HTMLBody},
+	}
+
+	for _, part := range parts {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+		partWriter, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = writeQuotedPrintable(partWriter, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func writeQuotedPrintable(destination io.Writer, content string) error {
+	writer := quotedprintable.NewWriter(destination)
+
+	if _, err := writer.Write([]byte(content)); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// ==================================================
+//                  SMTP
+// ==================================================
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	TLSMode  string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(message MailMessage) error {
+	if message.From == "" {
+		message.From = m.From
+	}
+
+	raw, err := buildRawEmail(message)
+	if err != nil {
+		return err
+	}
+
+	address := net.JoinHostPort(m.Host, m.Port)
+	tlsConfig := &tls.Config{ServerName: m.Host}
+
+	var client *smtp.Client
+
+	if m.TLSMode == "tls" {
+		conn, err := tls.Dial("tcp", address, tlsConfig)
+		if err != nil {
+			return err
+		}
+
+		client, err = smtp.NewClient(conn, m.Host)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+	} else {
+		client, err = smtp.Dial(address)
+		if err != nil {
+			return err
+		}
+	}
+	defer client.Close()
+
+	if m.TLSMode == "starttls" {
+		if err = client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if m.Username != "" {
+		auth := smtp.PlainAuth("", m.Username, m.Password, m.Host)
+		if err = client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	sender, err := mail.ParseAddress(message.From)
+	if err != nil {
+		return err
+	}
+
+	if err = client.Mail(sender.Address); err != nil {
+		return err
+	}
+
+	for _, receiver := range message.To {
+		address, err := mail.ParseAddress(receiver)
+		if err != nil {
+			return err
+		}
+
+		if err = client.Rcpt(address.Address); err != nil {
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err = writer.Write(raw); err != nil {
+		return err
+	}
+
+	if err = writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// ==================================================
+//                  File Spool
+// ==================================================
+
+// Write every email as an .eml file instead of sending it, handy for local development
+type FileSpoolMailer struct {
+	Directory string
+	From      string
+}
+
+func (m *FileSpoolMailer) Send(message MailMessage) error {
+	if message.From == "" {
+		message.From = m.From
+	}
+
+	raw, err := buildRawEmail(message)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(m.Directory, 0o755); err != nil {
+		return err
+	}
+
+	suffix, err := generateRandomToken(6)
+	if err != nil {
+		return err
+	}
+
+	filename := time.Now().Format("20060102-150405.000000") + "-" + suffix + ".eml"
+
+	return os.WriteFile(filepath.Join(m.Directory, filename), raw, 0o644)
+}
+
+// ==================================================
+//                  In Memory
+// ==================================================
+
+// Keep every email in memory, so that tests can inspect what would have been sent
+type MemoryMailer struct {
+	mutex    sync.Mutex
+	messages []MailMessage
+}
+
+func (m *MemoryMailer) Send(message MailMessage) error {
+	if message.From == "" {
+		message.From = "no-reply@localhost"
+	}
+
+	if _, err := buildRawEmail(message); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func (m *MemoryMailer) Messages() []MailMessage {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([]MailMessage{}, m.messages...)
+}
+
+func (m *MemoryMailer) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.messages = nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"strings"
+	texttemplate "text/template"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ==================================== Email Templates ===========================================
+// ================================================================================================
+// ================================================================================================
+//
+// Every email has two templates in ./templates/email : '<name>.txt' and '<name>.html'.
+// Both define a "subject" template, the one of the text version is used for the email header.
+// The html version only defines the "content" block, it is wrapped by 'layout.html'
+
+//go:embed templates/email/*
+var emailTemplateFiles embed.FS
+
+const (
+	EMAIL_REGISTRATION         string = "registration"
+	EMAIL_PASSWORD_RESET       string = "password_reset"
+	EMAIL_APPLICATION_RECEIVED string = "application_received"
+	EMAIL_APPLICATION_STATUS   string = "application_status"
+	EMAIL_NEW_MESSAGE          string = "new_message"
+	EMAIL_JOB_MATCH_DIGEST     string = "job_match_digest"
+	EMAIL_COMPANY_INVITATION   string = "company_invitation"
+)
+
+type RegistrationEmailData struct {
+	Username       string
+	Link           string
+	ExpiresInHours int
+}
+
+type PasswordResetEmailData struct {
+	Username         string
+	Link             string
+	ExpiresInMinutes int
+}
+
+type ApplicationReceivedEmailData struct {
+	Username string
+	JobTitle string
+}
+
+type ApplicationStatusEmailData struct {
+	Username string
+	JobTitle string
+	Status   string
+	Note     string
+}
+
+type NewMessageEmailData struct {
+	Username   string
+	SenderName string
+	Preview    string
+}
+
+type JobMatchDigestEmailData struct {
+	Username string
+	Jobs     []Job
+	Link     string
+}
+
+type CompanyInvitationEmailData struct {
+	CompanyName string
+	InviterName string
+	Role        string
+	Link        string
+}
+
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Templates are parsed at startup. A broken template is a programming error, hence the panic
+var emailTemplates map[string]emailTemplate = mustParseEmailTemplates()
+
+func mustParseEmailTemplates() map[string]emailTemplate {
+	names := []string{
+		EMAIL_REGISTRATION,
+		EMAIL_PASSWORD_RESET,
+		EMAIL_APPLICATION_RECEIVED,
+		EMAIL_APPLICATION_STATUS,
+		EMAIL_NEW_MESSAGE,
+		EMAIL_JOB_MATCH_DIGEST,
+		EMAIL_COMPANY_INVITATION,
+	}
+
+	templates := map[string]emailTemplate{}
+
+	for _, name := range names {
+		text, err := texttemplate.ParseFS(emailTemplateFiles, "templates/email/"+name+".txt")
+		if err != nil {
+			panic(fmt.Sprintf("unable to parse text email template '%s': %s", name, err.Error()))
+		}
+
+		html, err := htmltemplate.ParseFS(emailTemplateFiles, "templates/email/layout.html", "templates/email/"+name+".html")
+		if err != nil {
+			panic(fmt.Sprintf("unable to parse html email template '%s': %s", name, err.Error()))
+		}
+
+		templates[name] = emailTemplate{text: text, html: html}
+	}
+
+	return templates
+}
+
+func renderEmail(name string, data interface{}) (MailMessage, error) {
+	message := MailMessage{}
+
+	tmpl, ok := emailTemplates[name]
+	if !ok {
+		return message, fmt.Errorf("unknown email template '%s'", name)
+	}
+
+	buffer := bytes.Buffer{}
+
+	if err := tmpl.text.ExecuteTemplate(&buffer, "subject", data); err != nil {
+		return message, err
+	}
+	message.Subject = strings.TrimSpace(buffer.String())
+
+	buffer.Reset()
+	if err := tmpl.text.ExecuteTemplate(&buffer, name+".txt", data); err != nil {
+		return message, err
+	}
+	message.Body = buffer.String()
+
+	buffer.Reset()
+	if err := tmpl.html.ExecuteTemplate(&buffer, "layout.html", data); err != nil {
+		return message, err
+	}
+	message.HTMLBody = buffer.String()
+
+	return message, nil
+}
+
+// The email is only queued in the outbox, it is sent in the background by the outbox workers
+func sendTemplatedEmail(emailReceiver string, name string, data interface{}) error {
+	message, err := renderEmail(name, data)
+	if err != nil {
+		log.Println("Failed to render the email notification. Error : ", err.Error())
+		return err
+	}
+
+	message.To = []string{emailReceiver}
+
+	err = enqueueEmail(message, name)
+	if err != nil {
+		log.Println("Failed to queue the email notification. Error : ", err.Error())
+	}
+
+	return err
+}
@@ -2,18 +2,23 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"log"
-	"net/smtp"
+	"mime"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/joho/godotenv"
 	_ "github.com/mattn/go-sqlite3"
@@ -35,6 +40,10 @@ type UserPassport struct {
 type User struct {
 	UserPassport
 	UserCredential
+	Suspended           bool       `json:"suspended" gorm:"default:false"`
+	EmailVerified       bool       `json:"email_verified" gorm:"default:false"`
+	FailedLoginAttempts int        `json:"-" gorm:"default:0"`
+	LockedUntil         *time.Time `json:"locked_until"`
 }
 
 func (u *User) hideSensitiveData() {
@@ -58,46 +67,140 @@ func (u User) IsMandatoryFieldFilled() bool {
 	return match
 }
 
+// Only graduate and employer accounts can be self-registered, admin privilege is granted by another admin
+func (u User) isValidSelfRegistration() error {
+	if u.Admin {
+		return fmt.Errorf("Admin accounts can't be registered, ask an existing admin to promote you")
+	}
+
+	if u.Graduate == u.Employer {
+		return fmt.Errorf("Select exactly one account type: graduate or employer")
+	}
+
+	return nil
+}
+
+const (
+	WORKPLACE_ON_SITE string = "on-site"
+	WORKPLACE_HYBRID  string = "hybrid"
+	WORKPLACE_REMOTE  string = "remote"
+)
+
+const (
+	CONTRACT_INTERNSHIP string = "internship"
+	CONTRACT_FULL_TIME  string = "full-time"
+	CONTRACT_PART_TIME  string = "part-time"
+)
+
+const maxJobDescriptionLength int = 20000
+
 // Job properties inspired by : https://www.indeed.com/viewjob?jk=5d43c4aa2edf6f41&tk=1hh1n8q22jkuc800&from=serp&vjs=3
 type Job struct {
-	Id           int        `json:"id"`
-	Title        string     `json:"title"`
-	Yoe          float64    `json:"yoe"`
-	RoleId       int        `json:"role_id"`
-	Role         JobRole    `json:"role" gorm:"foreignKey:RoleId"`
-	Tree         []JobSkill `json:"tree" gorm:"many2many:job_skills_tree"`
-	IsRecruiting bool       `json:"is_recruiting" gorm:"default:true"`
+	Id                  int                   `json:"id"`
+	Title               string                `json:"title"`
+	Description         string                `json:"description"`
+	Yoe                 float64               `json:"yoe"`
+	RoleId              int                   `json:"role_id"`
+	Role                JobRole               `json:"role" gorm:"foreignKey:RoleId"`
+	Tree                []JobSkill            `json:"tree" gorm:"many2many:job_skills_tree"`
+	Skills              []JobSkillRequirement `json:"skills" gorm:"foreignKey:JobId"`
+	Questions           []ScreeningQuestion   `json:"questions" gorm:"foreignKey:JobId"`
+	Status              string                `json:"status" gorm:"index;default:published"`
+	SalaryMin           int                   `json:"salary_min"` // Yearly, 0 when not disclosed
+	SalaryMax           int                   `json:"salary_max"`
+	SalaryCurrency      string                `json:"salary_currency"` // ISO 4217 code, eg. XAF, EUR, USD
+	City                string                `json:"city"`
+	Country             string                `json:"country"`
+	WorkplaceType       string                `json:"workplace_type" gorm:"default:on-site"`
+	ContractType        string                `json:"contract_type" gorm:"default:full-time"`
+	ApplicationDeadline *time.Time            `json:"application_deadline"`
+	EmployerId          int                   `json:"employer_id" gorm:"index"`
+	Employer            User                  `json:"-" gorm:"foreignKey:EmployerId"`
+	CompanyId           int                   `json:"company_id" gorm:"index"`
+	CreatedAt           time.Time             `json:"created_at"`
+	UpdatedAt           time.Time             `json:"updated_at"`
+	PublishedAt         *time.Time            `json:"published_at"`
+	PausedAt            *time.Time            `json:"paused_at"`
+	ClosedAt            *time.Time            `json:"closed_at"`
+	ArchivedAt          *time.Time            `json:"archived_at"`
 	// Careful, this field must remain private (non-exported), otherwise it will break GORM functionalities. On the other and, this field must be in the same package as the db operation it is related with
 	// Status         bool     `json:"status"`
-	// Description    string   `json:"description"`
 	// Skills         []string `json:"skills"` // Skills & Year of experience (optional)
-	// Salary         []int    `json:"salary"` // Min & Max
-	// Company        string   `json:"company"`
-	// City           string   `json:"city"`
 }
 
+// Workplace and contract type are optional, the database defaults (on-site, full-time) apply when left empty
 func (j Job) isValid() error {
-	var err error = nil
+	if strings.TrimSpace(j.Title) == "" || j.RoleId <= 0 {
+		return fmt.Errorf("Job title and role are mandatory when creating new Job")
+	}
 
-	if j.Title == "" {
-		err = fmt.Errorf("Job title and role are mandatory when creating new Job")
-		return fiber.ErrBadGateway
+	if j.Yoe < 0 {
+		return fmt.Errorf("Minimum Year of Experience can't go below 0 for creating a new Job")
 	}
 
-	if j.Yoe <= 0 || j.RoleId <= 0 {
-		err = fmt.Errorf("Minimum Year of Experience can't go below 0 for creating a new Job")
-		return err
+	if len(j.Description) > maxJobDescriptionLength {
+		return fmt.Errorf("Job description can't be longer than %d characters", maxJobDescriptionLength)
 	}
 
-	return err
+	if j.SalaryMin < 0 || j.SalaryMax < 0 {
+		return fmt.Errorf("Salary can't be negative")
+	}
+
+	if j.SalaryMin > 0 && j.SalaryMax > 0 && j.SalaryMin > j.SalaryMax {
+		return fmt.Errorf("Minimum salary can't be greater than the maximum salary")
+	}
+
+	isSalaryDisclosed := j.SalaryMin > 0 || j.SalaryMax > 0
+	if isSalaryDisclosed && !regexp.MustCompile(`^[A-Z]{3}$`).MatchString(j.SalaryCurrency) {
+		return fmt.Errorf("Salary currency must be a 3 letters ISO 4217 code (eg. XAF, EUR, USD)")
+	}
+
+	switch j.WorkplaceType {
+	case "", WORKPLACE_ON_SITE, WORKPLACE_HYBRID, WORKPLACE_REMOTE:
+	default:
+		return fmt.Errorf("Unknown workplace type '%s', expected one of: on-site, hybrid, remote", j.WorkplaceType)
+	}
+
+	switch j.ContractType {
+	case "", CONTRACT_INTERNSHIP, CONTRACT_FULL_TIME, CONTRACT_PART_TIME:
+	default:
+		return fmt.Errorf("Unknown contract type '%s', expected one of: internship, full-time, part-time", j.ContractType)
+	}
+
+	workplaceType := j.WorkplaceType
+	if workplaceType == "" {
+		workplaceType = WORKPLACE_ON_SITE
+	}
+
+	// Candidates need to know where to go, unless the job is fully remote
+	if workplaceType != WORKPLACE_REMOTE && strings.TrimSpace(j.City) == "" && strings.TrimSpace(j.Country) == "" {
+		return fmt.Errorf("City or country is mandatory for %s jobs", workplaceType)
+	}
+
+	if j.ApplicationDeadline != nil && j.ApplicationDeadline.Before(time.Now()) {
+		return fmt.Errorf("Application deadline can't be in the past")
+	}
+
+	return validateScreeningQuestions(j.Questions)
 }
 
 type JobApplication struct {
-	Id         int  `json:"id"`
-	GraduateId int  `json:"graduate_id"`
-	JobId      int  `json:"job_id"`
-	Graduate   User `gorm:"foreignKey:GraduateId"`
-	Job        Job  `gorm:"foreignKey:JobId"`
+	Id           int                       `json:"id"`
+	GraduateId   int                       `json:"graduate_id"`
+	JobId        int                       `json:"job_id"`
+	Status       string                    `json:"status" gorm:"index;default:submitted"`
+	CreatedAt    time.Time                 `json:"created_at"`
+	UpdatedAt    time.Time                 `json:"updated_at"`
+	CoverLetter  string                    `json:"cover_letter"`
+	CvSnapshotId int                       `json:"cv_snapshot_id"`
+	Graduate     User                      `gorm:"foreignKey:GraduateId"`
+	Job          Job                       `gorm:"foreignKey:JobId"`
+	Answers      []ScreeningAnswer         `json:"answers" gorm:"foreignKey:ApplicationId"`
+	History      []ApplicationStatusChange `json:"history" gorm:"foreignKey:ApplicationId"`
+}
+
+func (j *JobApplication) hideSensitiveData() {
+	j.Graduate.hideSensitiveData()
 }
 
 func (j JobApplication) isValid() error {
@@ -116,7 +219,8 @@ func (j JobApplication) isValid() error {
 	jobs := []Job{}
 	graduates := []User{}
 
-	gormDB.Where("id = ? AND is_recruiting = true", j.JobId).Find(&jobs)
+	// The deadline is checked as well, jobs are only closed periodically once it passes
+	gormDB.Where("id = ? AND status = ? AND (application_deadline IS NULL OR application_deadline > ?)", j.JobId, JOB_PUBLISHED, time.Now()).Find(&jobs)
 	gormDB.Where("id = ?", j.GraduateId).Find(&graduates)
 
 	if len(jobs) == 0 || len(graduates) == 0 {
@@ -124,7 +228,12 @@ func (j JobApplication) isValid() error {
 		return err
 	}
 
-	return err
+	// Part 3: Check the cover letter and the answers to the job screening questions
+	if len(j.CoverLetter) > maxCoverLetterLength {
+		return fmt.Errorf("Cover letter can't be longer than %d characters", maxCoverLetterLength)
+	}
+
+	return validateScreeningAnswers(getScreeningQuestions(j.JobId), j.Answers)
 }
 
 type Friendship struct {
@@ -210,8 +319,11 @@ func (m Message) isValid() error {
 }
 
 type JobSkill struct {
-	Id   int    `json:"id"`
-	Name string `json:"name"`
+	Id             int          `json:"id"`
+	Name           string       `json:"name"`
+	ParentId       int          `json:"parent_id" gorm:"index"` // 0 for top level skills
+	NormalizedName string       `json:"-" gorm:"index"`
+	Aliases        []SkillAlias `json:"aliases,omitempty" gorm:"foreignKey:SkillId"`
 }
 
 type JobRole struct {
@@ -220,14 +332,24 @@ type JobRole struct {
 }
 
 type CurriculumVitae struct {
-	Id         int        `json:"id"`
-	Gpa        float64    `json:"gpa"`
-	Yoe        float64    `json:"yoe"`
-	GraduateId int        `json:"graduate_id"`
-	JobRoleId  int        `json:"job_role_id"`
-	Graduate   User       `json:"user" gorm:"foreignKey:GraduateId"`
-	JobRole    JobRole    `json:"job_role" gorm:"foreignKey:JobRoleId"`
-	Tree       []JobSkill `json:"tree" gorm:"many2many:graduate_skills_tree"`
+	Id             int              `json:"id"`
+	Gpa            float64          `json:"gpa"`
+	Yoe            float64          `json:"yoe"`
+	GraduateId     int              `json:"graduate_id" gorm:"uniqueIndex"`
+	JobRoleId      int              `json:"job_role_id"`
+	Graduate       User             `json:"user" gorm:"foreignKey:GraduateId"`
+	JobRole        JobRole          `json:"job_role" gorm:"foreignKey:JobRoleId"`
+	Tree           []JobSkill       `json:"tree" gorm:"many2many:graduate_skills_tree"`
+	Skills         []CVSkill        `json:"skills" gorm:"foreignKey:CurriculumVitaeId"`
+	Education      []Education      `json:"education" gorm:"foreignKey:CvId"`
+	Experience     []WorkExperience `json:"experience" gorm:"foreignKey:CvId"`
+	Projects       []Project        `json:"projects" gorm:"foreignKey:CvId"`
+	Certifications []Certification  `json:"certifications" gorm:"foreignKey:CvId"`
+	Languages      []SpokenLanguage `json:"languages" gorm:"foreignKey:CvId"`
+}
+
+func (cv *CurriculumVitae) hideSensitiveData() {
+	cv.Graduate.hideSensitiveData()
 }
 
 type SkillsTree struct {
@@ -241,12 +363,44 @@ func main() {
 	envApp, err := godotenv.Read("./.env")
 	env = envApp
 
+	// Without the file the defaults apply, but a file that can't be parsed would silently drop every setting
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Fatal("Unable to parse the .env file. ", err.Error())
+	}
+
 	if err != nil {
 		log.Println("Error loading the .env file. ", err.Error())
-		log.Println("As a consequence, no email notification will be sent to new registered users")
+		log.Println("As a consequence, email notifications will be written to ./mail_spool instead of being sent")
+	}
+
+	if envApp["FRONTEND_URL"] == "" {
+		log.Println("[Warning] FRONTEND_URL is not set, password reset and invitation links sent by email will lead to the API server")
+	}
+
+	mailer, err = newMailerFromEnv(envApp)
+	if err != nil {
+		log.Fatal("Unable to configure the mailer. ", err.Error())
 	}
 
-	fmt.Println(envApp)
+	blobStore, err = newBlobStoreFromEnv(envApp)
+	if err != nil {
+		log.Fatal("Unable to configure the file storage. ", err.Error())
+	}
+
+	rateLimitStore, err = newRateLimitStoreFromEnv(envApp)
+	if err != nil {
+		log.Fatal("Unable to configure the rate limiter. ", err.Error())
+	}
+
+	jobScorer, err = newScorerFromEnv(envApp)
+	if err != nil {
+		log.Fatal("Unable to configure the job matching. ", err.Error())
+	}
+
+	err = loadSigningKeys(envApp)
+	if err != nil {
+		log.Fatal("Unable to load the JWT signing keys. ", err.Error())
+	}
 
 	// 1 -- Database Definition
 	// os.Remove("./jobs.gormDb")
@@ -264,23 +418,82 @@ func main() {
 	}
 
 	gormDB = gormDb
+
+	// Accounts registered before email verification existed are trusted, they would be locked out otherwise.
+	// Checked before any migration, since migrating a table referencing 'users' also migrates 'users'
+	isVerificationColumnNew := !gormDb.Migrator().HasColumn(&User{}, "EmailVerified")
+
+	// The skills trees carry the requirement of each skill, not only the skill
+	err = gormDb.SetupJoinTable(&Job{}, "Tree", &JobSkillRequirement{})
+	printError(err)
+	err = gormDb.SetupJoinTable(&CurriculumVitae{}, "Tree", &CVSkill{})
+	printError(err)
+
 	// gormDB.Migrator().DropTable(&Job{})
 	err = gormDb.AutoMigrate(&Job{})
 	printError(err)
+	err = migrateJobRecruitingFlag(gormDb)
+	printError(err)
+	// Jobs posted before the workplace and contract types existed get the same defaults as new ones
+	err = gormDb.Model(&Job{}).Where("workplace_type IS NULL OR workplace_type = ''").Update("workplace_type", WORKPLACE_ON_SITE).Error
+	printError(err)
+	err = gormDb.Model(&Job{}).Where("contract_type IS NULL OR contract_type = ''").Update("contract_type", CONTRACT_FULL_TIME).Error
+	printError(err)
 	err = gormDb.AutoMigrate(&JobRole{})
 	printError(err)
 	err = gormDb.AutoMigrate(&JobSkill{})
 	printError(err)
+	err = gormDb.AutoMigrate(&SkillAlias{})
+	printError(err)
+	err = migrateSkillNormalizedNames(gormDb)
+	printError(err)
 	err = gormDb.AutoMigrate(&User{})
 	printError(err)
+	if isVerificationColumnNew {
+		err = gormDb.Model(&User{}).Where("1 = 1").Update("email_verified", true).Error
+		printError(err)
+	}
 	err = gormDb.AutoMigrate(&JobApplication{})
 	printError(err)
+	err = gormDb.AutoMigrate(&ApplicationStatusChange{})
+	printError(err)
+	err = gormDb.AutoMigrate(&ScreeningQuestion{})
+	printError(err)
+	err = gormDb.AutoMigrate(&ScreeningAnswer{})
+	printError(err)
+	err = gormDb.AutoMigrate(&Attachment{})
+	printError(err)
+	err = gormDb.AutoMigrate(&Education{}, &WorkExperience{}, &Project{}, &Certification{}, &SpokenLanguage{})
+	printError(err)
 	err = gormDb.AutoMigrate(&Friendship{})
 	printError(err)
 	err = gormDb.AutoMigrate(&Message{})
 	printError(err)
+	// Must run before the unique index on the graduate of a CV is created, once the CV sections tables exist
+	err = migrateDuplicateCVs(gormDb)
+	printError(err)
 	err = gormDb.AutoMigrate(&CurriculumVitae{})
 	printError(err)
+	err = gormDb.AutoMigrate(&CVSnapshot{})
+	printError(err)
+	err = gormDb.AutoMigrate(&RefreshToken{})
+	printError(err)
+	err = gormDb.AutoMigrate(&PasswordResetToken{})
+	printError(err)
+	err = gormDb.AutoMigrate(&OutboxEmail{})
+	printError(err)
+	err = gormDb.AutoMigrate(&OutboxAttempt{})
+	printError(err)
+	err = gormDb.AutoMigrate(&AuditEntry{})
+	printError(err)
+	err = gormDb.AutoMigrate(&RateLimitCounter{})
+	printError(err)
+	err = gormDb.AutoMigrate(&Company{})
+	printError(err)
+	err = gormDb.AutoMigrate(&CompanyMember{})
+	printError(err)
+	err = gormDb.AutoMigrate(&CompanyInvitation{})
+	printError(err)
 
 	db, err := sql.Open("sqlite3", "./jobs.db")
 	if err != nil {
@@ -290,8 +503,19 @@ func main() {
 
 	DB = db
 
+	mailWorkers, err := strconv.Atoi(envApp["MAIL_WORKERS"])
+	if err != nil || mailWorkers <= 0 {
+		mailWorkers = 2
+	}
+
+	startOutboxWorkers(mailWorkers)
+	startJobDeadlineWatcher()
+
 	// 2 -- Launching the server
-	app := fiber.New()
+	// Leave room for the multipart envelope around the uploaded file
+	app := fiber.New(fiber.Config{
+		BodyLimit: getUploadMaxSize() + 1024*1024,
+	})
 	setupRoute(app)
 
 	port := ":2200"
@@ -299,12 +523,32 @@ func main() {
 }
 
 var (
-	secret_key string = "hello"
-	gormDB     *gorm.DB
-	DB         *sql.DB
-	env        map[string]string
+	gormDB    *gorm.DB
+	DB        *sql.DB
+	env       map[string]string
+	mailer    Mailer
+	blobStore BlobStore
 )
 
+// Base URL of this API server, used to build the links sent by email that the server answers itself (GET)
+func getAppURL() string {
+	if env["APP_URL"] != "" {
+		return strings.TrimSuffix(env["APP_URL"], "/")
+	}
+
+	return "http://localhost:2200"
+}
+
+// Base URL of the client application (FRONTEND_URL), for the links sent by email that lead to one of its pages :
+// /reset-password?token=... and /companies/invitations/accept?token=..., the page then calls the API
+func getFrontendURL() string {
+	if env["FRONTEND_URL"] != "" {
+		return strings.TrimSuffix(env["FRONTEND_URL"], "/")
+	}
+
+	return getAppURL()
+}
+
 func setupRoute(app *fiber.App) {
 	app.Use(func(c *fiber.Ctx) error {
 		fmt.Println("Hello From CORS policy manager handler !")
@@ -312,7 +556,7 @@ func setupRoute(app *fiber.App) {
 		method := c.Route().Method
 		fmt.Println("Method: ", method)
 		c.Set("Access-Control-Allow-Origin", "*")
-		c.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		c.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		c.Set("Access-Control-Allow-Credentials", "true")
 		c.Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
 
@@ -338,7 +582,12 @@ func setupRoute(app *fiber.App) {
 
 	api := app.Group("/api/v1")
 
-	api.Post("/registration", func(c *fiber.Ctx) error {
+	registrationRateLimitByIP := rateLimitMiddleware("registration-ip", 5, time.Hour, rateLimitKeyByIP)
+	loginRateLimitByIP := rateLimitMiddleware("login-ip", 30, time.Minute, rateLimitKeyByIP)
+	loginRateLimitByUsername := rateLimitMiddleware("login-username", 10, time.Minute, rateLimitKeyByUsername)
+	emailRateLimitByIP := rateLimitMiddleware("email-ip", 5, 15*time.Minute, rateLimitKeyByIP)
+
+	api.Post("/registration", registrationRateLimitByIP, func(c *fiber.Ctx) error {
 		user := &User{}
 
 		if err := c.BodyParser(user); err != nil {
@@ -355,6 +604,17 @@ func setupRoute(app *fiber.App) {
 			})
 		}
 
+		if err := user.isValidSelfRegistration(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		// Never trust the client for those, they are managed by the server
+		user.Id = 0
+		user.Suspended = false
+		user.EmailVerified = false
+
 		// Check that the user doesn't already exist before creating it
 		existingUser := &[]User{}
 		gormDB.Limit(1).Find(existingUser, "username = ?", user.Username)
@@ -365,39 +625,93 @@ func setupRoute(app *fiber.App) {
 			})
 		}
 
-		err := sendGmailNotification(user.Email, user.Username, user.Password)
+		hash, err := hashPassword(user.Password)
+		if err != nil {
+			fmt.Println("Password hashing error: ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Unable to register the user",
+			})
+		}
+
+		user.Password = hash
+		gormDB.Create(user)
+
+		err = sendVerificationEmail(*user)
 		if err != nil {
 			fmt.Println("Failed to send mail ? ---> ", err.Error())
 			// return c.SendStatus(fiber.StatusInternalServerError)
 		}
 
-		gormDB.Create(user)
+		user.hideSensitiveData()
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
 			"data": user,
 		})
 	})
 
-	api.Post("/login", func(c *fiber.Ctx) error {
+	api.Post("/login", loginRateLimitByIP, loginRateLimitByUsername, func(c *fiber.Ctx) error {
 		// Fetch User data
 		userCredential := UserCredential{}
 
 		if err := c.BodyParser(&userCredential); err != nil {
-			c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
 		// Check User in gormDB
 		existingUsers := []User{}
-		gormDB.Where("username = ? AND password = ?", userCredential.Username, userCredential.Password).Limit(1).Find(&existingUsers)
+		gormDB.Where("username = ?", userCredential.Username).Limit(1).Find(&existingUsers)
 
 		if len(existingUsers) == 0 {
+			// Still pay the cost of a hash comparison, so that response time doesn't reveal which usernames exist
+			verifyPassword(dummyPasswordHash, userCredential.Password)
+
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid Username or Password",
+			})
+		}
+
+		isMatch, isLegacy := verifyPassword(existingUsers[0].Password, userCredential.Password)
+
+		// A locked account answers like a wrong password, whether the password matched or not,
+		// so that neither the lock nor a guessed password reveal anything
+		if isAccountLocked(existingUsers[0]) {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"message": "Invalid Username or Password",
 			})
 		}
 
+		if !isMatch {
+			recordFailedLogin(existingUsers[0], c.IP())
+
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid Username or Password",
+			})
+		}
+
+		resetFailedLogins(existingUsers[0])
+
+		if existingUsers[0].Suspended {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "This account has been suspended",
+			})
+		}
+
+		if !existingUsers[0].EmailVerified && isEmailVerificationRequired() {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "Email address not verified, check your inbox for the verification link",
+			})
+		}
+
+		if isLegacy {
+			if err := migrateLegacyPassword(&existingUsers[0], userCredential.Password); err != nil {
+				fmt.Println("Password migration error: ", err.Error())
+			}
+		}
+
+		userCredential.Password = ""
+
 		// If user found, send token back to client
 		userPassport := UserPassport{
 			Id:       existingUsers[0].Id,
@@ -406,697 +720,2074 @@ func setupRoute(app *fiber.App) {
 			Employer: existingUsers[0].Employer,
 		}
 
-		claims := jwt.MapClaims{
-			"passport": userPassport,
-			// "exp":      time.Now().Add(time.Minute * 5).Unix(),
+		token_string, refresh_token, err := issueTokenPair(userPassport)
+		if err != nil {
+			fmt.Println("Token creation error: ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Unable to create the user session",
+			})
 		}
 
-		key := []byte(secret_key)
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-		token_string, _ := token.SignedString(key)
-
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
 			"token":           token_string,
+			"refresh_token":   refresh_token,
+			"expires_in":      int(accessTokenLifetime.Seconds()),
 			"user_passport":   userPassport,
 			"user_credential": userCredential,
 		})
 	})
 
-	// ==================================================
-	// ==================================================
-	//                   Middleware
-	// ==================================================
-	// ==================================================
-
-	api.Use(jwtMiddlewareProtect)
-
-	api.Get("/jobs", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
-		availableJobs := []Job{}
-
-		gormDB.Model(&Job{}).
-			Where("is_recruiting = true").
-			Preload("Tree").
-			Preload("Role").
-			Find(&availableJobs)
-
-		/*
-			for _, el := range availableJobs {
-				el.tree = []JobSkill{{Name: "oreimo"}}
-			}
-		*/
+	api.Get("/verify-email", func(c *fiber.Ctx) error {
+		user, err := verifyEmailToken(c.Query("token"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 
-		fmt.Println(availableJobs)
+		user.hideSensitiveData()
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"jobs": availableJobs,
+			"user": user,
 		})
 	})
 
-	api.Post("/jobs", employerOnlyMiddleware, func(c *fiber.Ctx) error {
-		job := Job{}
+	api.Post("/verify-email/resend", emailRateLimitByIP, func(c *fiber.Ctx) error {
+		userCredential := UserCredential{}
 
-		if err := c.BodyParser(&job); err != nil {
+		if err := c.BodyParser(&userCredential); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
-		if err := job.isValid(); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"message": err.Error(),
-			})
-		}
+		users := []User{}
+		gormDB.Where("email = ? AND email_verified = false", userCredential.Email).Find(&users)
 
-		gormDB.Create(&job)
+		for _, user := range users {
+			if err := sendVerificationEmail(user); err != nil {
+				fmt.Println("Failed to send mail ? ---> ", err.Error())
+			}
+		}
 
+		// Same answer whether or not the email is known, to avoid leaking registered addresses
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"job": job,
+			"message": "If an unverified account uses this email, a new verification link has been sent",
 		})
 	})
 
-	api.Get("/jobs/filtered/:my_id<int>?", graduateEmployerOnlyMiddleware, func(c *fiber.Ctx) error {
-		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
-		var param string = c.Params("my_id")
-
-		user_id, err := strconv.Atoi(param)
-		if err != nil {
-			user_id = passport.Id
-		}
-
-		cv := CurriculumVitae{}
-		err = gormDB.
-			Preload("Graduate").
-			Preload("JobRole").
-			Preload("Tree").
-			Where("graduate_id = ?", user_id).
-			First(&cv).Error
+	api.Post("/password/forgot", emailRateLimitByIP, func(c *fiber.Ctx) error {
+		userCredential := UserCredential{}
 
-		if err != nil {
-			fmt.Println("Error while loading user CV: ", err.Error())
-			return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		if err := c.BodyParser(&userCredential); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
-		jobs := []Job{}
-		err = gormDB.
-			Where("is_recruiting = true").
-			Preload("Role").
-			Preload("Tree").
-			Find(&jobs).Error
+		users := []User{}
+		gormDB.Where("email = ? AND suspended = false", userCredential.Email).Find(&users)
 
-		if err != nil {
-			fmt.Println("Error while laod Job from DB: ", err.Error())
-			return c.Status(fiber.StatusOK).JSON(fiber.Map{
-				"message": err.Error(),
-			})
+		for _, user := range users {
+			if err := sendPasswordResetEmail(user); err != nil {
+				fmt.Println("Failed to send mail ? ---> ", err.Error())
+			}
 		}
 
-		filteredJobs := filterJobsByElligibility(cv, jobs)
-
+		// Same answer whether or not the email is known, to avoid leaking registered addresses
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"jobs": filteredJobs,
+			"message": "If an account uses this email, a password reset link has been sent",
 		})
 	})
 
-	api.Post("/jobs/skills", employerOnlyMiddleware, func(c *fiber.Ctx) error {
-		type JobSkillTree struct {
-			Job_id   int `json:"job_id"`
-			Skill_id int `json:"job_skill_id"`
+	api.Post("/password/reset", func(c *fiber.Ctx) error {
+		type ResetRequest struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
 		}
-		skillTree := JobSkillTree{}
+		request := ResetRequest{}
 
-		if err := c.BodyParser(&skillTree); err != nil {
-			fmt.Println(err.Error())
+		if err := c.BodyParser(&request); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
-		err := saveJobSkillsToDB(DB, skillTree.Job_id, skillTree.Skill_id)
-		if err != nil {
-			fmt.Println(err.Error())
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		if err := resetPasswordWithToken(request.Token, request.Password); err != nil {
+			fmt.Println("[POST /password/reset] ", err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"tree": skillTree,
+			"message": "Password updated, you can now login with your new password",
 		})
 	})
 
-	api.Post("/jobs/close/", employerOnlyMiddleware, func(c *fiber.Ctx) error {
-		job := Job{}
-
-		if err := c.BodyParser(&job); err != nil {
-			fmt.Println("data parsing error: ", err.Error())
+	api.Get("/token/keys", func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"keys": getPublicJWKS(),
+		})
+	})
 
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"message": err.Error(),
-			})
+	api.Post("/token/refresh", func(c *fiber.Ctx) error {
+		type RefreshRequest struct {
+			RefreshToken string `json:"refresh_token"`
 		}
+		request := RefreshRequest{}
 
-		jobSelector := Job{Id: job.Id}
-		err := gormDB.
-			Model(&jobSelector).
-			Update("is_recruiting", job.IsRecruiting).
-			Error
-		if err != nil {
-			fmt.Println("DB error: ", err.Error())
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		if err := c.BodyParser(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"job_update": job,
-		})
-	})
-
-	api.Get("/jobs/hidden", func(c *fiber.Ctx) error {
-		hiddenJobs := []Job{}
-
-		err := gormDB.
-			Where("is_recruiting = false").
-			Find(&hiddenJobs).Error
+		token_string, refresh_token, userPassport, err := rotateRefreshToken(request.RefreshToken)
 		if err != nil {
-			fmt.Println("DB error: ", err.Error())
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			fmt.Println("[POST /token/refresh] ", err.Error())
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"jobs": hiddenJobs,
+			"token":         token_string,
+			"refresh_token": refresh_token,
+			"expires_in":    int(accessTokenLifetime.Seconds()),
+			"user_passport": userPassport,
 		})
 	})
 
-	// FIXME: "GET" methods send user password as well
-	// Remove it from the JSON response
-	api.Post("/application", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
-		application := JobApplication{}
+	api.Post("/logout", func(c *fiber.Ctx) error {
+		type LogoutRequest struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		request := LogoutRequest{}
 
-		if err := c.BodyParser(&application); err != nil {
+		if err := c.BodyParser(&request); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
-		if err := application.isValid(); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		if err := revokeSessionFromRefreshToken(request.RefreshToken); err != nil {
+			fmt.Println("[POST /logout] ", err.Error())
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
-		gormDB.Create(&application)
+		return c.SendStatus(fiber.StatusNoContent)
+	})
 
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"job_application": application,
+	// ==================================================
+	// ==================================================
+	//                   Middleware
+	// ==================================================
+	// ==================================================
+
+	api.Use(jwtMiddlewareProtect)
+
+	api.Post("/password/change", func(c *fiber.Ctx) error {
+		type ChangeRequest struct {
+			OldPassword string `json:"old_password"`
+			NewPassword string `json:"new_password"`
+		}
+		request := ChangeRequest{}
+
+		if err := c.BodyParser(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		passport := getUserPassportFromMiddlewareContext(c)
+		sessionId, _ := c.Locals("session_id").(string)
+
+		if err := changePassword(passport.Id, sessionId, request.OldPassword, request.NewPassword); err != nil {
+			fmt.Println("[POST /password/change] ", err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "Password updated",
+		})
+	})
+
+	api.Get("/jobs", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
+		availableJobs := []Job{}
+
+		gormDB.Model(&Job{}).
+			Where("status = ?", JOB_PUBLISHED).
+			Scopes(preloadSkills).
+			Preload("Questions", preloadScreeningQuestions).
+			Preload("Role").
+			Find(&availableJobs)
+
+		/*
+			for _, el := range availableJobs {
+				el.tree = []JobSkill{{Name: "oreimo"}}
+			}
+		*/
+
+		fmt.Println(availableJobs)
+		hideKnockOutCriteria(availableJobs)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"jobs": availableJobs,
+		})
+	})
+
+	api.Post("/jobs", employerOnlyMiddleware, func(c *fiber.Ctx) error {
+		job := Job{}
+
+		if err := c.BodyParser(&job); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		if err := job.isValid(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+
+		// The job belongs to whoever posted it, never to the one named in the request
+		job.Id = 0
+		job.EmployerId = passport.Id
+
+		normalizeScreeningQuestions(job.Questions)
+
+		// A job is published right away, unless saved as draft to be reviewed first
+		requestedStatus := job.Status
+		job.Status = JOB_DRAFT
+		job.PublishedAt, job.PausedAt, job.ClosedAt, job.ArchivedAt = nil, nil, nil, nil
+
+		if requestedStatus == "" {
+			requestedStatus = JOB_PUBLISHED
+		}
+
+		if requestedStatus != JOB_DRAFT {
+			if err := job.transitionTo(requestedStatus); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"message": err.Error(),
+				})
+			}
+		}
+
+		// Recruiters of a single company post for it by default, others must tell which company the job is for
+		if job.CompanyId == 0 {
+			if companyIds := getPostingCompanyIds(passport.Id); len(companyIds) == 1 {
+				job.CompanyId = companyIds[0]
+			}
+		} else if !hasCompanyRole(passport, job.CompanyId, COMPANY_OWNER, COMPANY_RECRUITER) {
+			return forbidden(c, "Forbidden, you can only post jobs for a company you recruit for")
+		}
+
+		// Skills are added with POST /jobs/skills, where they are validated. Only the screening questions are saved with the job
+		job.Role, job.Employer = JobRole{}, User{}
+		job.Tree, job.Skills = nil, nil
+
+		err := gormDB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Omit(clause.Associations).Create(&job).Error; err != nil {
+				return err
+			}
+
+			return replaceScreeningQuestions(tx, job.Id, job.Questions)
+		})
+		if err != nil {
+			fmt.Println("[POST /jobs] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"job": job,
+		})
+	})
+
+	api.Get("/employer/jobs", employerOnlyMiddleware, func(c *fiber.Ctx) error {
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+		jobs := []Job{}
+
+		err := gormDB.
+			Where("employer_id = ? OR company_id IN ?", passport.Id, getMemberCompanyIds(passport.Id)).
+			Preload("Role").
+			Scopes(preloadSkills).
+			Preload("Questions", preloadScreeningQuestions).
+			Find(&jobs).Error
+		if err != nil {
+			fmt.Println("[GET /employer/jobs] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"jobs": jobs,
+		})
+	})
+
+	api.Get("/jobs/filtered/:my_id<int>?", graduateEmployerOnlyMiddleware, selfOnlyMiddleware("my_id"), func(c *fiber.Ctx) error {
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+		var param string = c.Params("my_id")
+
+		user_id, err := strconv.Atoi(param)
+		if err != nil {
+			user_id = passport.Id
+		}
+
+		cv := CurriculumVitae{}
+		err = gormDB.
+			Preload("Graduate").
+			Preload("JobRole").
+			Scopes(preloadSkills).
+			Where("graduate_id = ?", user_id).
+			First(&cv).Error
+
+		if err != nil {
+			fmt.Println("Error while loading user CV: ", err.Error())
+			return c.Status(fiber.StatusOK).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		jobs := []Job{}
+		err = gormDB.
+			Where("status = ?", JOB_PUBLISHED).
+			Preload("Role").
+			Scopes(preloadSkills).
+			Preload("Questions", preloadScreeningQuestions).
+			Find(&jobs).Error
+
+		if err != nil {
+			fmt.Println("Error while laod Job from DB: ", err.Error())
+			return c.Status(fiber.StatusOK).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		hideKnockOutCriteria(jobs)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"jobs": jobScorer.MatchJobs(cv, jobs),
+		})
+	})
+
+	// Why the caller's CV does or doesn't match the job, and what closing each gap would bring
+	api.Get("/jobs/:job_id<int>/gap", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
+		jobId, _ := strconv.Atoi(c.Params("job_id"))
+
+		job, err := findJobById(jobId)
+		if err != nil || job.Status != JOB_PUBLISHED {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Job not found in the system",
+			})
+		}
+
+		cv, err := findGraduateCV(getUserPassportFromMiddlewareContext(c).Id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"gap": jobScorer.AnalyzeGap(cv, job),
+		})
+	})
+
+	// Graduates ranked by how well their CV matches the job. Query: page, per_page, min_score (the matching threshold by default)
+	// Every CV is loaded and scored in memory, then paginated, which is fine as long as graduates are counted in thousands
+	api.Get("/jobs/:job_id<int>/candidates", employerOnlyMiddleware, jobManagerOnlyMiddleware("job_id"), func(c *fiber.Ctx) error {
+		jobId, _ := strconv.Atoi(c.Params("job_id"))
+
+		job, err := findJobById(jobId)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		minScore := jobScorer.Weights.Threshold
+		if param := c.Query("min_score"); param != "" {
+			minScore, err = strconv.ParseFloat(param, 64)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"message": "min_score must be a number",
+				})
+			}
+		}
+
+		page := max(c.QueryInt("page", 1), 1)
+		perPage := min(max(c.QueryInt("per_page", 20), 1), 100)
+
+		cvs := []CurriculumVitae{}
+		err = gormDB.
+			Joins("Graduate").
+			Preload("JobRole").
+			Scopes(preloadSkills).
+			Where("Graduate.suspended = ?", false).
+			Find(&cvs).Error
+		if err != nil {
+			fmt.Println("[GET /jobs/:job_id/candidates] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		applications := []JobApplication{}
+		gormDB.Select("id", "graduate_id").Where("job_id = ?", jobId).Find(&applications)
+
+		applicationByGraduate := map[int]int{}
+		for _, application := range applications {
+			applicationByGraduate[application.GraduateId] = application.Id
+		}
+
+		candidates := jobScorer.RankCandidates(job, cvs, applicationByGraduate, minScore)
+		total := len(candidates)
+
+		start := min((page-1)*perPage, total)
+		candidates = candidates[start:min(start+perPage, total)]
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"candidates": candidates,
+			"page":       page,
+			"per_page":   perPage,
+			"total":      total,
+		})
+	})
+
+	api.Post("/jobs/skills", employerOnlyMiddleware, func(c *fiber.Ctx) error {
+		skillTree := JobSkillRequirement{}
+
+		if err := c.BodyParser(&skillTree); err != nil {
+			fmt.Println(err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		if !canManageJob(getUserPassportFromMiddlewareContext(c), skillTree.JobId) {
+			return forbidden(c, "Forbidden, you can only manage your own jobs")
+		}
+
+		err := saveJobSkillRequirement(&skillTree)
+		if err != nil {
+			fmt.Println(err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"tree": skillTree,
+		})
+	})
+
+	// PUT replaces every editable field of the job, PATCH only those present in the body
+	updateJobHandler := func(isPartial bool) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			jobId, _ := strconv.Atoi(c.Params("job_id"))
+
+			job, err := findJobById(jobId)
+			if err != nil {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"message": err.Error(),
+				})
+			}
+
+			job, err = updateJob(job, c.Body(), isPartial)
+			if err != nil {
+				fmt.Println("[", c.Method(), " /jobs/:id] ", err.Error())
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"message": err.Error(),
+				})
+			}
+
+			return c.Status(fiber.StatusOK).JSON(fiber.Map{
+				"job": job,
+			})
+		}
+	}
+
+	api.Put("/jobs/:job_id<int>", employerOnlyMiddleware, jobManagerOnlyMiddleware("job_id"), updateJobHandler(false))
+	api.Patch("/jobs/:job_id<int>", employerOnlyMiddleware, jobManagerOnlyMiddleware("job_id"), updateJobHandler(true))
+
+	api.Delete("/jobs/:job_id<int>", employerOnlyMiddleware, jobManagerOnlyMiddleware("job_id"), func(c *fiber.Ctx) error {
+		jobId, _ := strconv.Atoi(c.Params("job_id"))
+
+		if err := deleteJob(jobId); err != nil {
+			fmt.Println("[DELETE /jobs/:id] ", err.Error())
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	// Kept for clients written before the job lifecycle : recruiting jobs are published, the others closed
+	api.Post("/jobs/close/", employerOnlyMiddleware, func(c *fiber.Ctx) error {
+		type CloseRequest struct {
+			Id           int  `json:"id"`
+			IsRecruiting bool `json:"is_recruiting"`
+		}
+		request := CloseRequest{}
+
+		if err := c.BodyParser(&request); err != nil {
+			fmt.Println("data parsing error: ", err.Error())
+
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		if !canManageJob(getUserPassportFromMiddlewareContext(c), request.Id) {
+			return forbidden(c, "Forbidden, you can only manage your own jobs")
+		}
+
+		job, err := findJobById(request.Id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		status := JOB_CLOSED
+		if request.IsRecruiting {
+			status = JOB_PUBLISHED
+		}
+
+		if job.Status != status {
+			job, err = setJobStatus(job, status)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"message": err.Error(),
+				})
+			}
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"job_update": job,
+		})
+	})
+
+	// Employers only see their own paused and closed jobs (or their company ones), admins see them all
+	api.Get("/jobs/hidden", employerOnlyMiddleware, func(c *fiber.Ctx) error {
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+		hiddenJobs := []Job{}
+
+		query := gormDB.Where("status IN ?", []string{JOB_PAUSED, JOB_CLOSED})
+		if !passport.Admin {
+			query = query.Where("employer_id = ? OR company_id IN ?", passport.Id, getMemberCompanyIds(passport.Id))
+		}
+
+		err := query.Find(&hiddenJobs).Error
+		if err != nil {
+			fmt.Println("DB error: ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"jobs": hiddenJobs,
+		})
+	})
+
+	api.Post("/application", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
+		application := JobApplication{}
+
+		if err := c.BodyParser(&application); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		if !isSelfOrAdmin(getUserPassportFromMiddlewareContext(c), application.GraduateId) {
+			return forbidden(c, "Forbidden, you can only apply on your own behalf")
+		}
+
+		if err := application.isValid(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		if err := submitApplication(&application); err != nil {
+			fmt.Println("[POST /application] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		notifyApplicationReceived(application)
+		application = applyKnockOutQuestions(application)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"job_application": application,
+		})
+	})
+
+	api.Get("/application", adminOnlyMiddleware, func(c *fiber.Ctx) error {
+		applications := []JobApplication{}
+
+		gormDB.Preload("Job").Preload("Graduate").Preload("Answers").Preload("History", preloadApplicationHistory).Find(&applications)
+		hideSensitiveApplicationData(&applications)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"job_applications": applications,
+		})
+	})
+
+	api.Get("/application/:job_id<int>/:graduate_id<int>", graduateEmployerOnlyMiddleware, func(c *fiber.Ctx) error {
+		var jobId string = c.Params("job_id")
+		var graduateId string = c.Params("graduate_id")
+
+		applications := []JobApplication{}
+
+		gormDB.Preload("Graduate").Preload("Job").Preload("Answers").Preload("History", preloadApplicationHistory).
+			Where("job_id = ? AND graduate_id = ?", jobId, graduateId).
+			Find(&applications)
+
+		if len(applications) == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Application to this Job not found for this Graduate",
+			})
+		}
+
+		if len(applications) > 1 {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": "Internal Server Error -- Multiple Applications found to the same Job for this Graduate",
+			})
+		}
+
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+
+		if !canAccessApplication(passport, applications[0]) {
+			return forbidden(c, "Forbidden, you can't access this application")
+		}
+
+		if passport.Id != applications[0].GraduateId && canManageJob(passport, applications[0].JobId) {
+			applications[0] = markApplicationViewed(applications[0], passport.Id)
+		}
+
+		applications[0].hideSensitiveData()
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"job_application": applications[0],
+		})
+	})
+
+	api.Post("/application/:application_id<int>/status", employerOnlyMiddleware, func(c *fiber.Ctx) error {
+		type StatusRequest struct {
+			Status string `json:"status"`
+			Note   string `json:"note"`
+		}
+		request := StatusRequest{}
+
+		if err := c.BodyParser(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+		applicationId, _ := strconv.Atoi(c.Params("application_id"))
+
+		application, err := findApplicationById(applicationId)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		if !canManageJob(passport, application.JobId) {
+			return forbidden(c, "Forbidden, you can only manage the applications of your own jobs")
+		}
+
+		application, err = moveApplication(application, request.Status, request.Note, passport.Id)
+		if err != nil {
+			fmt.Println("[POST /application/:id/status] ", err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"job_application": application,
+		})
+	})
+
+	// The CV as it was when the application was submitted
+	api.Get("/application/:application_id<int>/cv", graduateEmployerOnlyMiddleware, func(c *fiber.Ctx) error {
+		applicationId, _ := strconv.Atoi(c.Params("application_id"))
+
+		application, err := findApplicationById(applicationId)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		if !canAccessApplication(getUserPassportFromMiddlewareContext(c), application) {
+			return forbidden(c, "Forbidden, you can't access this application")
+		}
+
+		snapshots := []CVSnapshot{}
+		gormDB.Where("id = ?", application.CvSnapshotId).Limit(1).Find(&snapshots)
+
+		if len(snapshots) == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "No CV was attached to this application",
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"cv_snapshot": snapshots[0],
+		})
+	})
+
+	api.Post("/application/:application_id<int>/withdraw", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
+		type WithdrawRequest struct {
+			Note string `json:"note"`
+		}
+		request := WithdrawRequest{}
+
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&request); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"message": err.Error(),
+				})
+			}
+		}
+
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+		applicationId, _ := strconv.Atoi(c.Params("application_id"))
+
+		application, err := findApplicationById(applicationId)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		if !isSelfOrAdmin(passport, application.GraduateId) {
+			return forbidden(c, "Forbidden, you can only withdraw your own applications")
+		}
+
+		application, err = withdrawApplication(application, request.Note, passport.Id)
+		if err != nil {
+			fmt.Println("[POST /application/:id/withdraw] ", err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"job_application": application,
+		})
+	})
+
+	api.Get("/application/job/:job_id", employerOnlyMiddleware, jobViewerOnlyMiddleware("job_id"), func(c *fiber.Ctx) error {
+		var jobId string = c.Params("job_id")
+
+		applications := []JobApplication{}
+		gormDB.Preload("Graduate").Preload("Job").Preload("Answers").Preload("History", preloadApplicationHistory).
+			Where("job_id = ?", jobId).
+			Find(&applications)
+		hideSensitiveApplicationData(&applications)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"job_applications": applications,
+		})
+	})
+
+	api.Get("/application/graduate/:graduate_id", graduateOnlyMiddleware, selfOnlyMiddleware("graduate_id"), func(c *fiber.Ctx) error {
+		var graduateId string = c.Params("graduate_id")
+
+		applications := []JobApplication{}
+		gormDB.Preload("Graduate").Preload("Job").Preload("Answers").Preload("History", preloadApplicationHistory).
+			Where("graduate_id = ?", graduateId).
+			Find(&applications)
+		hideSensitiveApplicationData(&applications)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"job_applications": applications,
+		})
+	})
+
+	api.Get("/user/graduate", func(c *fiber.Ctx) error {
+		graduates := []User{}
+
+		gormDB.Where("graduate = true").Find(&graduates)
+		hideSensitiveUserData(&graduates)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"graduates": graduates,
+		})
+	})
+
+	api.Get("/user/graduate/filtered/:my_id<int>?", selfOnlyMiddleware("my_id"), func(c *fiber.Ctx) error {
+		passport := getUserPassportFromMiddlewareContext(c)
+		param := c.Params("my_id")
+		user_id, err := strconv.Atoi(param)
+
+		if err != nil {
+			user_id = passport.Id
+		}
+
+		cv := CurriculumVitae{}
+		err = gormDB.
+			Preload("Graduate").
+			Preload("JobRole").
+			Scopes(preloadSkills).
+			Where("graduate_id = ?", user_id).
+			First(&cv).Error
+
+		if err != nil {
+			fmt.Println("CV Db fetch error: ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		graduatesCvs := []CurriculumVitae{}
+		err = gormDB.
+			Preload("Graduate").
+			Preload("JobRole").
+			Scopes(preloadSkills).
+			Where("graduate_id <> ?", user_id).
+			Find(&graduatesCvs).Error
+
+		if err != nil {
+			fmt.Println("CVS Db fetch error: ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		filteredCvs := filterGraduatesByCvToFindPotentialFriends(cv, graduatesCvs)
+		hideSensitiveCVData(&filteredCvs)
+
+		// Other graduates are only suggested as friends, their email isn't shared with them
+		if !passport.Admin {
+			for key := range filteredCvs {
+				filteredCvs[key].Graduate.Email = ""
+			}
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"cvs": filteredCvs,
+		})
+	})
+
+	api.Get("/user/employer", func(c *fiber.Ctx) error {
+		employers := []User{}
+
+		gormDB.Where("employer = true").Find(&employers)
+		hideSensitiveUserData(&employers)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"employers": employers,
+		})
+	})
+
+	api.Get("/friends", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+		friends := []Friendship{}
+
+		query := gormDB.Preload("From").Preload("To")
+		if !passport.Admin {
+			query = query.Where("from_id = ? OR to_id = ?", passport.Id, passport.Id)
+		}
+
+		query.Find(&friends)
+
+		hideSensitiveFriendshipData(&friends)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"friends": friends,
+		})
+	})
+
+	api.Post("/friends", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
+		friendship := Friendship{}
+
+		if err := c.BodyParser(&friendship); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		if !isSelfOrAdmin(getUserPassportFromMiddlewareContext(c), friendship.FromId) {
+			return forbidden(c, "Forbidden, you can only send friend requests on your own behalf")
+		}
+
+		if err := friendship.isValid(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		gormDB.Create(&friendship)
+
+		friendship.hideSensitiveData()
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"friends": friendship,
+		})
+	})
+
+	api.Get("/friends/:my_id", graduateOnlyMiddleware, selfOnlyMiddleware("my_id"), func(c *fiber.Ctx) error {
+		id := c.Params("my_id")
+
+		friends := []Friendship{}
+		gormDB.Where("from_id = ? OR to_id = ?", id, id).
+			Preload("From").Preload("To").
+			Find(&friends)
+
+		hideSensitiveFriendshipData(&friends)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"friends": friends,
+		})
+	})
+
+	// TODO: Enforce the id parameter to be <int> (":my_id<int>", "friend_id<int>")
+	api.Get("/friends/:my_id/:friend_id", graduateOnlyMiddleware, selfOnlyMiddleware("my_id"), func(c *fiber.Ctx) error {
+		myId := c.Params("my_id")
+		friendId := c.Params("friend_id")
+
+		friends := []Friendship{}
+		gormDB.Where("(from_id = ? AND to_id = ?) OR (from_id = ? AND to_id = ?)", myId, friendId, friendId, myId).
+			Preload("From").Preload("To").
+			Find(&friends)
+
+		if len(friends) == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Friendship not found",
+			})
+		}
+
+		hideSensitiveFriendshipData(&friends)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"friend": friends[0],
+		})
+	})
+
+	api.Get("/messages", func(c *fiber.Ctx) error {
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+		messages := []Message{}
+
+		query := gormDB.Model(&Message{})
+		if !passport.Admin {
+			query = query.Where("sender_id = ? OR receiver_id = ?", passport.Id, passport.Id)
+		}
+
+		query.Find(&messages)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"messages": messages,
+		})
+	})
+
+	api.Post("/messages", func(c *fiber.Ctx) error {
+		message := Message{}
+
+		if err := c.BodyParser(&message); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		if !isSelfOrAdmin(getUserPassportFromMiddlewareContext(c), message.SenderId) {
+			return forbidden(c, "Forbidden, you can only send messages on your own behalf")
+		}
+
+		if err := message.isValid(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		gormDB.Create(&message)
+
+		notifyNewMessage(message)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": message,
+		})
+	})
+
+	api.Get("/messages/:sender_id<int>/:receiver_id<int>", conversationOnlyMiddleware("sender_id", "receiver_id"), func(c *fiber.Ctx) error {
+		senderId := c.Params("sender_id")
+		receiverId := c.Params("receiver_id")
+
+		messages := []Message{}
+		gormDB.Where("sender_id = ? AND receiver_id = ? OR sender_id = ? AND receiver_id = ?", senderId, receiverId, receiverId, senderId).
+			Find(&messages)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"messages": messages,
+		})
+	})
+
+	api.Get("/messages/lasts/:user_id<int>", selfOnlyMiddleware("user_id"), func(c *fiber.Ctx) error {
+		user_id := c.Params("user_id")
+
+		messages := []Message{}
+		gormDB.Where("sender_id = ? OR receiver_id = ?", user_id, user_id).
+			Find(&messages)
+
+		if len(messages) == 0 {
+			return c.Status(fiber.StatusOK).JSON(fiber.Map{
+				"messages": messages,
+			})
+		}
+
+		// Finding out the last message for each conversion between two users
+		counter := 0
+		lastMessages := []Message{}
+
+		lastMessages = append(lastMessages, messages[0])
+
+		for _, message := range messages {
+			for key, lastMsg := range lastMessages {
+				if (lastMsg.SenderId == message.SenderId && lastMsg.ReceiverId == message.ReceiverId) ||
+					(lastMsg.SenderId == message.ReceiverId && lastMsg.ReceiverId == message.SenderId) {
+					lastMessages[key] = message
+					break
+				}
+
+				counter = key
+			}
+
+			if counter == len(lastMessages)-1 {
+				lastMessages = append(lastMessages, message)
+			}
+		}
+
+		messages = lastMessages[1:]
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"messages": messages,
+		})
+	})
+
+	api.Get("/cv", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+		cvs := []CurriculumVitae{}
+
+		query := gormDB.Model(&CurriculumVitae{})
+		if !passport.Admin {
+			query = query.Where("graduate_id = ?", passport.Id)
+		}
+
+		err := query.
+			Preload("Graduate").
+			Preload("JobRole").
+			Scopes(preloadSkills).
+			Scopes(preloadCVSections).
+			Find(&cvs).Error
+		// cvs, err := getAllGraduateCurriculumViateFromDB(DB)
+
+		if err != nil {
+			log.Println("[API route ./cv] error while fetching all graduate cv. ", err.Error())
+			return c.Status(fiber.StatusNoContent).JSON(fiber.Map{
+				"error": err,
+			})
+		}
+
+		hideSensitiveCVData(&cvs)
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"cv": cvs,
+		})
+	})
+
+	api.Get("/cv/:my_id<int>", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
+		cvId := c.Params("my_id")
+
+		cv := CurriculumVitae{}
+
+		err := gormDB.Where("id = ?", cvId).
+			Preload("Graduate").
+			Preload("JobRole").
+			Scopes(preloadSkills).
+			Scopes(preloadCVSections).
+			First(&cv).Error
+
+		if err != nil {
+			fmt.Println("DB error: ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		}
+
+		if !canAccessCV(getUserPassportFromMiddlewareContext(c), cv) {
+			return forbidden(c, "Forbidden, you can only access your own CV")
+		}
+
+		cv.hideSensitiveData()
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"cv": cv,
+		})
+	})
+
+	api.Post("/cv", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
+		cv := CurriculumVitae{}
+
+		if err := c.BodyParser(&cv); err != nil {
+			message := fmt.Sprintln("[POST /cv] Error while parsing input data. Error: ", err.Error())
+			log.Print(message)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": message,
+			})
+		}
+
+		// The CV always belongs to the caller, whatever graduate is named in the request
+		err := createCV(&cv, getUserPassportFromMiddlewareContext(c).Id)
+		if errors.Is(err, errCVAlreadyExists) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		if err != nil {
+			message := "Unable to save the data to database"
+			log.Println(message, " ---> ", err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": message,
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"cv": cv,
+		})
+	})
+
+	api.Put("/cv", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
+		changes := CurriculumVitae{}
+
+		if err := c.BodyParser(&changes); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		cv, err := updateCV(getUserPassportFromMiddlewareContext(c).Id, changes)
+		if err != nil {
+			fmt.Println("[PUT /cv] ", err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"cv": cv,
+		})
+	})
+
+	api.Delete("/cv", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
+		cv, err := findGraduateCV(getUserPassportFromMiddlewareContext(c).Id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		if err := deleteCV(cv); err != nil {
+			fmt.Println("[DELETE /cv] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	/*
+		api.Get("/cv/simple", func(c *fiber.Ctx) error {
+			cvs, err := getCurriculumVitae(DB)
+			if err != nil {
+				fmt.Println("[GET /cv/simple] unable to get data from db. Error : ", err.Error())
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"message": "Unable to get curriculum_vitae from database",
+				})
+			}
+
+			return c.Status(fiber.StatusOK).JSON(fiber.Map{
+				"cvs": cvs,
+			})
+		})
+	*/
+
+	api.Post("/cv/skills", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
+		skill := CVSkill{}
+
+		if err := c.BodyParser(&skill); err != nil {
+			fmt.Println("[POST /cv/skills] Error :", err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+		if skill.CurriculumVitaeId <= 0 {
+			cv := CurriculumVitae{}
+			err := gormDB.Where("graduate_id = ?", passport.Id).First(&cv).Error
+
+			if err != nil {
+				fmt.Println("DB error while searching for user CV: ", err.Error())
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"message": err.Error(),
+				})
+			}
+
+			skill.CurriculumVitaeId = cv.Id
+		} else {
+			cv := CurriculumVitae{}
+			err := gormDB.Where("id = ?", skill.CurriculumVitaeId).First(&cv).Error
+
+			if err != nil {
+				fmt.Println("DB error while searching for user CV: ", err.Error())
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+					"message": err.Error(),
+				})
+			}
+
+			if !canAccessCV(passport, cv) {
+				return forbidden(c, "Forbidden, you can only update your own CV")
+			}
+		}
+
+		err := saveCVSkill(&skill)
+		if err != nil {
+			fmt.Println("[POST /cv/skills] Error :", err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"skill": skill,
+		})
+	})
+
+	api.Delete("/cv/skills/:skill_id<int>", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
+		cv, err := findGraduateCV(getUserPassportFromMiddlewareContext(c).Id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		skillId, _ := strconv.Atoi(c.Params("skill_id"))
+
+		if err := removeCVSkill(cv.Id, skillId); err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	setupCVSectionRoutes[Education](api, "education", "education", "start_date desc")
+	setupCVSectionRoutes[WorkExperience](api, "experience", "experience", "start_date desc")
+	setupCVSectionRoutes[Project](api, "projects", "project", "start_date desc")
+	setupCVSectionRoutes[Certification](api, "certifications", "certification", "issued_at desc")
+	setupCVSectionRoutes[SpokenLanguage](api, "languages", "language", "id")
+
+	api.Get("/skills", func(c *fiber.Ctx) error {
+		skills := []JobSkill{}
+
+		err := gormDB.Preload("Aliases").Order("name").Find(&skills).Error
+
+		if err != nil {
+			fmt.Println("[Error while fetching job_skills from db] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"skills": skills,
+		})
+	})
+
+	api.Post("/skills", func(c *fiber.Ctx) error {
+		request := SkillRequest{}
+
+		if err := c.BodyParser(&request); err != nil {
+			fmt.Println("[POST /skills] request parsing error : ", err.Error())
+			return c.SendStatus(fiber.StatusBadRequest)
+		}
+
+		// A name already known, in any case or as an alias, returns the existing skill
+		skill, err := createSkill(request)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"skill": skill,
+		})
+	})
+
+	api.Get("/job_roles", func(c *fiber.Ctx) error {
+		roles := []JobRole{}
+
+		err := gormDB.Find(&roles).Error
+		// roles, err := getJobRoleFromDB(DB)
+
+		if err != nil {
+			fmt.Println("[GET /job_roles] ", err.Error())
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"job_roles": roles,
+		})
+	})
+
+	api.Post("/job_roles", func(c *fiber.Ctx) error {
+		role := JobRole{}
+
+		if err := c.BodyParser(&role); err != nil {
+			fmt.Println("[POST /job_roles] ", err.Error())
+			return c.SendStatus(fiber.StatusBadRequest)
+		}
+
+		err := gormDB.Create(&role).Error
+		// err := saveJobRoleToDB(DB, role)
+		if err != nil {
+			fmt.Println("[POST /job_roles] ", err.Error())
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"job_role": role,
+		})
+	})
+
+	// ==================================================
+	//                  Attachments
+	// ==================================================
+
+	api.Post("/attachments", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "The file to upload is missing from the 'file' form field",
+			})
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			fmt.Println("[POST /attachments] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+		defer file.Close()
+
+		// One byte more than allowed is enough to know the file is too big
+		content, err := io.ReadAll(io.LimitReader(file, int64(getUploadMaxSize())+1))
+		if err != nil {
+			fmt.Println("[POST /attachments] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+
+		attachment, err := storeAttachment(passport.Id, c.FormValue("kind"), fileHeader.Filename, content)
+		if err != nil {
+			fmt.Println("[POST /attachments] ", err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"attachment": attachment,
 		})
 	})
 
-	// TODO: Add additional "Middleware" to protect this route
-	api.Get("/application", func(c *fiber.Ctx) error {
-		applications := []JobApplication{}
+	api.Get("/attachments/graduate/:graduate_id<int>", func(c *fiber.Ctx) error {
+		graduateId, _ := strconv.Atoi(c.Params("graduate_id"))
+
+		if !canAccessGraduateFiles(getUserPassportFromMiddlewareContext(c), graduateId) {
+			return forbidden(c, "Forbidden, you can't access the files of this graduate")
+		}
 
-		gormDB.Preload("Job").Preload("Graduate").Find(&applications)
+		attachments := []Attachment{}
+		err := gormDB.Where("owner_id = ?", graduateId).Order("created_at desc").Find(&attachments).Error
+		if err != nil {
+			fmt.Println("[GET /attachments/graduate/:id] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"job_applications": applications,
+			"attachments": attachments,
 		})
 	})
 
-	// TODO: Add additional "Middleware" to protect this route
-	api.Get("/application/:job_id<int>/:graduate_id<int>", func(c *fiber.Ctx) error {
-		var jobId string = c.Params("job_id")
-		var graduateId string = c.Params("graduate_id")
+	api.Get("/attachments/:attachment_id<int>/download", func(c *fiber.Ctx) error {
+		attachmentId, _ := strconv.Atoi(c.Params("attachment_id"))
 
-		applications := []JobApplication{}
+		attachment, err := findAttachmentById(attachmentId)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 
-		gormDB.Preload("Graduate").Preload("Job").
-			Where("job_id = ? AND graduate_id = ?", jobId, graduateId).
-			Find(&applications)
+		if !canAccessGraduateFiles(getUserPassportFromMiddlewareContext(c), attachment.OwnerId) {
+			return forbidden(c, "Forbidden, you can't access the files of this graduate")
+		}
 
-		if len(applications) == 0 {
+		content, err := blobStore.Get(attachment.Hash)
+		if err != nil {
+			fmt.Println("[GET /attachments/:id/download] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		c.Set(fiber.HeaderContentType, attachment.ContentType)
+		c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+		c.Set("X-Content-Type-Options", "nosniff")
+
+		return c.Status(fiber.StatusOK).SendStream(content, attachment.Size)
+	})
+
+	api.Delete("/attachments/:attachment_id<int>", func(c *fiber.Ctx) error {
+		attachmentId, _ := strconv.Atoi(c.Params("attachment_id"))
+
+		attachment, err := findAttachmentById(attachmentId)
+		if err != nil {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"message": "Application to this Job not found for this Graduate",
+				"message": err.Error(),
 			})
 		}
 
-		if len(applications) > 1 {
+		if !isSelfOrAdmin(getUserPassportFromMiddlewareContext(c), attachment.OwnerId) {
+			return forbidden(c, "Forbidden, you can only delete your own files")
+		}
+
+		if err := deleteAttachment(attachment); err != nil {
+			fmt.Println("[DELETE /attachments/:id] ", err.Error())
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"message": "Internal Server Error -- Multiple Applications found to the same Job for this Graduate",
+				"message": err.Error(),
 			})
 		}
 
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"job_application": applications[0],
-		})
+		return c.SendStatus(fiber.StatusNoContent)
 	})
 
-	api.Get("/application/job/:job_id", employerOnlyMiddleware, func(c *fiber.Ctx) error {
-		var jobId string = c.Params("job_id")
+	// ==================================================
+	//                  Companies
+	// ==================================================
 
-		applications := []JobApplication{}
-		gormDB.Preload("Graduate").Preload("Job").
-			Where("job_id = ?", jobId).
-			Find(&applications)
+	api.Post("/companies", employerOnlyMiddleware, func(c *fiber.Ctx) error {
+		company := Company{}
+
+		if err := c.BodyParser(&company); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		if err := company.isValid(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		// The creator becomes the first owner of the company
+		err := createCompany(&company, getUserPassportFromMiddlewareContext(c).Id)
+		if err != nil {
+			fmt.Println("[POST /companies] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"job_applications": applications,
+			"company": company,
 		})
 	})
 
-	api.Get("/application/graduate/:graduate_id", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
-		var graduateId string = c.Params("graduate_id")
+	api.Get("/companies", func(c *fiber.Ctx) error {
+		companies := []Company{}
 
-		applications := []JobApplication{}
-		gormDB.Preload("Graduate").Preload("Job").
-			Where("graduate_id = ?", graduateId).
-			Find(&applications)
+		err := gormDB.Find(&companies).Error
+		if err != nil {
+			fmt.Println("[GET /companies] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"job_applications": applications,
+			"companies": companies,
 		})
 	})
 
-	api.Get("/user/graduate", func(c *fiber.Ctx) error {
-		graduates := []User{}
+	api.Get("/companies/:company_id<int>", func(c *fiber.Ctx) error {
+		companyId, _ := strconv.Atoi(c.Params("company_id"))
 
-		gormDB.Where("graduate = true").Find(&graduates)
+		company, err := findCompanyById(companyId)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		// Only members see who works for the company
+		if !hasCompanyRole(getUserPassportFromMiddlewareContext(c), companyId, COMPANY_OWNER, COMPANY_RECRUITER, COMPANY_VIEWER) {
+			company.Members = nil
+		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"graduates": graduates,
+			"company": company,
 		})
 	})
 
-	api.Get("/user/graduate/filtered/:my_id<int>?", func(c *fiber.Ctx) error {
-		passport := getUserPassportFromMiddlewareContext(c)
-		param := c.Params("my_id")
-		user_id, err := strconv.Atoi(param)
+	api.Put("/companies/:company_id<int>", companyRoleMiddleware("company_id", COMPANY_OWNER), func(c *fiber.Ctx) error {
+		companyId, _ := strconv.Atoi(c.Params("company_id"))
+		company := Company{}
 
-		if err != nil {
-			user_id = passport.Id
+		if err := c.BodyParser(&company); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
 		}
 
-		cv := CurriculumVitae{}
-		err = gormDB.
-			Preload("Graduate").
-			Preload("JobRole").
-			Preload("Tree").
-			Where("graduate_id = ?", user_id).
-			First(&cv).Error
+		if err := company.isValid(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 
+		err := gormDB.Model(&Company{Id: companyId}).
+			Select("Name", "Description", "Website", "Location", "Logo").
+			Updates(&company).Error
 		if err != nil {
-			fmt.Println("CV Db fetch error: ", err.Error())
+			fmt.Println("[PUT /companies/:id] ", err.Error())
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
-		graduatesCvs := []CurriculumVitae{}
-		err = gormDB.
-			Preload("Graduate").
-			Preload("JobRole").
-			Preload("Tree").
-			Where("graduate_id <> ?", user_id).
-			Find(&graduatesCvs).Error
+		company, err = findCompanyById(companyId)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"company": company,
+		})
+	})
+
+	api.Get("/companies/:company_id<int>/jobs", func(c *fiber.Ctx) error {
+		var companyId string = c.Params("company_id")
+		jobs := []Job{}
 
+		err := gormDB.
+			Where("company_id = ?", companyId).
+			Preload("Role").
+			Scopes(preloadSkills).
+			Preload("Questions", preloadScreeningQuestions).
+			Find(&jobs).Error
 		if err != nil {
-			fmt.Println("CVS Db fetch error: ", err.Error())
+			fmt.Println("[GET /companies/:id/jobs] ", err.Error())
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
-		filteredCvs := filterGraduatesByCvToFindPotentialFriends(cv, graduatesCvs)
+		hideKnockOutCriteria(jobs)
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"cvs": filteredCvs,
+			"jobs": jobs,
 		})
 	})
 
-	api.Get("/user/employer", func(c *fiber.Ctx) error {
-		employers := []User{}
+	api.Post("/companies/:company_id<int>/invitations", companyRoleMiddleware("company_id", COMPANY_OWNER), func(c *fiber.Ctx) error {
+		type InvitationRequest struct {
+			Email string `json:"email"`
+			Role  string `json:"role"`
+		}
+		request := InvitationRequest{}
 
-		gormDB.Where("employer = true").Find(&employers)
+		if err := c.BodyParser(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		if request.Role == "" {
+			request.Role = COMPANY_RECRUITER
+		}
+
+		companyId, _ := strconv.Atoi(c.Params("company_id"))
+
+		inviter, err := findUserById(getUserPassportFromMiddlewareContext(c).Id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		invitation, err := inviteToCompany(companyId, inviter, request.Email, request.Role)
+		if err != nil {
+			fmt.Println("[POST /companies/:id/invitations] ", err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"employers": employers,
+			"invitation": invitation,
 		})
 	})
 
-	api.Get("/friends", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
-		friends := []Friendship{}
-
-		gormDB.Preload("From").Preload("To").Find(&friends)
+	api.Get("/companies/:company_id<int>/invitations", companyRoleMiddleware("company_id", COMPANY_OWNER), func(c *fiber.Ctx) error {
+		var companyId string = c.Params("company_id")
+		invitations := []CompanyInvitation{}
 
-		hideSensitiveFriendshipData(&friends)
+		err := gormDB.
+			Where("company_id = ?", companyId).
+			Order("created_at desc").
+			Find(&invitations).Error
+		if err != nil {
+			fmt.Println("[GET /companies/:id/invitations] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"friends": friends,
+			"invitations": invitations,
 		})
 	})
 
-	api.Post("/friends", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
-		friendship := Friendship{}
+	api.Post("/companies/invitations/accept", employerOnlyMiddleware, func(c *fiber.Ctx) error {
+		type AcceptRequest struct {
+			Token string `json:"token"`
+		}
+		request := AcceptRequest{}
 
-		if err := c.BodyParser(&friendship); err != nil {
+		if err := c.BodyParser(&request); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
-		if err := friendship.isValid(); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		user, err := findUserById(getUserPassportFromMiddlewareContext(c).Id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
-		gormDB.Create(&friendship)
-
-		friendship.hideSensitiveData()
+		member, err := acceptCompanyInvitation(request.Token, user)
+		if err != nil {
+			fmt.Println("[POST /companies/invitations/accept] ", err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"friends": friendship,
+			"member": member,
 		})
 	})
 
-	api.Get("/friends/:my_id", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
-		id := c.Params("my_id")
+	api.Patch("/companies/:company_id<int>/members/:user_id<int>", companyRoleMiddleware("company_id", COMPANY_OWNER), func(c *fiber.Ctx) error {
+		type RoleRequest struct {
+			Role string `json:"role"`
+		}
+		request := RoleRequest{}
 
-		friends := []Friendship{}
-		gormDB.Where("from_id = ? OR to_id = ?", id, id).
-			Preload("From").Preload("To").
-			Find(&friends)
+		if err := c.BodyParser(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 
-		hideSensitiveFriendshipData(&friends)
+		companyId, _ := strconv.Atoi(c.Params("company_id"))
+		userId, _ := strconv.Atoi(c.Params("user_id"))
+
+		member, err := setCompanyMemberRole(companyId, userId, request.Role)
+		if err != nil {
+			fmt.Println("[PATCH /companies/:id/members/:user_id] ", err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"friends": friends,
+			"member": member,
 		})
 	})
 
-	// TODO: Enforce the id parameter to be <int> (":my_id<int>", "friend_id<int>")
-	api.Get("/friends/:my_id/:friend_id", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
-		myId := c.Params("my_id")
-		friendId := c.Params("friend_id")
+	// Owners remove members, any member can leave the company by removing itself
+	api.Delete("/companies/:company_id<int>/members/:user_id<int>", func(c *fiber.Ctx) error {
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
 
-		friends := []Friendship{}
-		gormDB.Where("(from_id = ? AND to_id = ?) OR (from_id = ? AND to_id = ?)", myId, friendId, friendId, myId).
-			Preload("From").Preload("To").
-			Find(&friends)
+		companyId, _ := strconv.Atoi(c.Params("company_id"))
+		userId, _ := strconv.Atoi(c.Params("user_id"))
 
-		if len(friends) == 0 {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"message": "Friendship not found",
-			})
+		if passport.Id != userId && !hasCompanyRole(passport, companyId, COMPANY_OWNER) {
+			return forbidden(c, "Forbidden, you don't have the required role in this company")
 		}
 
-		hideSensitiveFriendshipData(&friends)
+		if err := removeCompanyMember(companyId, userId); err != nil {
+			fmt.Println("[DELETE /companies/:id/members/:user_id] ", err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"friend": friends[0],
-		})
+		return c.SendStatus(fiber.StatusNoContent)
 	})
 
-	api.Get("/messages", func(c *fiber.Ctx) error {
-		messages := []Message{}
+	// ==================================================
+	//                  Admin
+	// ==================================================
+
+	admin := api.Group("/admin", adminOnlyMiddleware)
 
-		gormDB.Find(&messages)
+	admin.Get("/users", func(c *fiber.Ctx) error {
+		users := []User{}
+		query := gormDB.Model(&User{})
+
+		role := c.Query("role")
+		if role != "" {
+			if !isValidRole(role) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"message": "Unknown role '" + role + "'",
+				})
+			}
+
+			query = query.Where(role + " = true")
+		}
+
+		err := query.Find(&users).Error
+		if err != nil {
+			fmt.Println("[GET /admin/users] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
+
+		hideSensitiveUserData(&users)
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"messages": messages,
+			"users": users,
 		})
 	})
 
-	api.Post("/messages", func(c *fiber.Ctx) error {
-		message := Message{}
+	type RoleRequest struct {
+		Role string `json:"role"`
+	}
 
-		if err := c.BodyParser(&message); err != nil {
+	admin.Post("/users/:user_id<int>/promote", func(c *fiber.Ctx) error {
+		userId, _ := strconv.Atoi(c.Params("user_id"))
+		request := RoleRequest{}
+
+		if err := c.BodyParser(&request); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
-		if err := message.isValid(); err != nil {
+		user, err := setUserRole(userId, request.Role, true)
+		if err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
-		gormDB.Create(&message)
+		user.hideSensitiveData()
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"message": message,
+			"user": user,
 		})
 	})
 
-	api.Get("/messages/:sender_id<int>/:receiver_id<int>", func(c *fiber.Ctx) error {
-		senderId := c.Params("sender_id")
-		receiverId := c.Params("receiver_id")
+	admin.Post("/users/:user_id<int>/demote", func(c *fiber.Ctx) error {
+		userId, _ := strconv.Atoi(c.Params("user_id"))
+		request := RoleRequest{}
 
-		messages := []Message{}
-		gormDB.Where("sender_id = ? AND receiver_id = ? OR sender_id = ? AND receiver_id = ?", senderId, receiverId, receiverId, senderId).
-			Find(&messages)
+		if err := c.BodyParser(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 
-		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"messages": messages,
-		})
-	})
+		if userId == getUserPassportFromMiddlewareContext(c).Id && request.Role == ROLE_ADMIN {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "You can't remove your own admin privilege",
+			})
+		}
 
-	api.Get("/messages/lasts/:user_id<int>", func(c *fiber.Ctx) error {
-		user_id := c.Params("user_id")
+		user, err := setUserRole(userId, request.Role, false)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 
-		messages := []Message{}
-		gormDB.Where("sender_id = ? OR receiver_id = ?", user_id, user_id).
-			Find(&messages)
+		// The passport inside the current tokens still holds the removed role
+		if err = revokeUserSessions(userId); err != nil {
+			fmt.Println("[POST /admin/users/demote] ", err.Error())
+		}
 
-		// Finding out the last message for each conversion between two users
-		counter := 0
-		lastMessages := []Message{}
+		user.hideSensitiveData()
 
-		lastMessages = append(lastMessages, messages[0])
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"user": user,
+		})
+	})
 
-		for _, message := range messages {
-			for key, lastMsg := range lastMessages {
-				if (lastMsg.SenderId == message.SenderId && lastMsg.ReceiverId == message.ReceiverId) ||
-					(lastMsg.SenderId == message.ReceiverId && lastMsg.ReceiverId == message.SenderId) {
-					lastMessages[key] = message
-					break
-				}
+	admin.Post("/users/:user_id<int>/suspend", func(c *fiber.Ctx) error {
+		userId, _ := strconv.Atoi(c.Params("user_id"))
 
-				counter = key
-			}
+		if userId == getUserPassportFromMiddlewareContext(c).Id {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "You can't suspend your own account",
+			})
+		}
 
-			if counter == len(lastMessages)-1 {
-				lastMessages = append(lastMessages, message)
-			}
+		user, err := setUserSuspended(userId, true)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
 		}
 
-		messages = lastMessages[1:]
+		user.hideSensitiveData()
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"messages": messages,
+			"user": user,
 		})
 	})
 
-	api.Get("/cv", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
-		cvs := []CurriculumVitae{}
-
-		err := gormDB.Model(&CurriculumVitae{}).
-			Preload("Graduate").
-			Preload("JobRole").
-			Preload("Tree").
-			Find(&cvs).Error
-		// cvs, err := getAllGraduateCurriculumViateFromDB(DB)
+	admin.Post("/users/:user_id<int>/unsuspend", func(c *fiber.Ctx) error {
+		userId, _ := strconv.Atoi(c.Params("user_id"))
 
+		user, err := setUserSuspended(userId, false)
 		if err != nil {
-			log.Println("[API route ./cv] error while fetching all graduate cv. ", err.Error())
-			return c.Status(fiber.StatusNoContent).JSON(fiber.Map{
-				"error": err,
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
 			})
 		}
 
+		user.hideSensitiveData()
+
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"cv": cvs,
+			"user": user,
 		})
 	})
 
-	api.Get("/cv/:my_id<int>", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
-		cvId := c.Params("my_id")
+	admin.Delete("/users/:user_id<int>", func(c *fiber.Ctx) error {
+		userId, _ := strconv.Atoi(c.Params("user_id"))
 
-		cv := CurriculumVitae{}
+		if userId == getUserPassportFromMiddlewareContext(c).Id {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": "You can't delete your own account",
+			})
+		}
 
-		err := gormDB.Where("id = ?", cvId).
-			Preload("Graduate").
-			Preload("JobRole").
-			Preload("Tree").
-			First(&cv).Error
+		if err := deleteUser(userId); err != nil {
+			fmt.Println("[DELETE /admin/users] ", err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
+		}
 
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	admin.Post("/users/:user_id<int>/unlock", func(c *fiber.Ctx) error {
+		userId, _ := strconv.Atoi(c.Params("user_id"))
+
+		user, err := findUserById(userId)
 		if err != nil {
-			fmt.Println("DB error: ", err.Error())
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
 		}
 
+		resetFailedLogins(user)
+		recordAuditEvent(AUDIT_ACCOUNT_UNLOCKED, user, c.IP(), "Unlocked by admin "+strconv.Itoa(getUserPassportFromMiddlewareContext(c).Id))
+
+		user.LockedUntil = nil
+		user.hideSensitiveData()
+
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"cv": cv,
+			"user": user,
 		})
 	})
 
-	api.Post("/cv", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
-		cv := CurriculumVitae{}
+	admin.Patch("/skills/:skill_id<int>", func(c *fiber.Ctx) error {
+		skillId, _ := strconv.Atoi(c.Params("skill_id"))
+		request := SkillRequest{}
 
-		if err := c.BodyParser(&cv); err != nil {
-			message := fmt.Sprintln("[POST /cv] Error while parsing input data. Error: ", err.Error())
-			log.Print(message)
+		if err := c.BodyParser(&request); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"message": message,
+				"message": err.Error(),
 			})
 		}
 
-		err := gormDB.Create(&cv).Error
-		// err := saveCurriculumVitae(DB, cv)
+		skill, err := updateSkill(skillId, request)
 		if err != nil {
-			message := "Unable to save the data to database"
-			log.Println(message, " ---> ", err.Error())
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"message": message,
+				"message": err.Error(),
 			})
 		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"cv": cv,
+			"skill": skill,
 		})
 	})
 
-	/*
-		api.Get("/cv/simple", func(c *fiber.Ctx) error {
-			cvs, err := getCurriculumVitae(DB)
-			if err != nil {
-				fmt.Println("[GET /cv/simple] unable to get data from db. Error : ", err.Error())
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"message": "Unable to get curriculum_vitae from database",
-				})
-			}
+	// Merge the skill 'duplicate_id' into this one, the duplicate is deleted
+	admin.Post("/skills/:skill_id<int>/merge", func(c *fiber.Ctx) error {
+		skillId, _ := strconv.Atoi(c.Params("skill_id"))
+		request := struct {
+			DuplicateId int `json:"duplicate_id"`
+		}{}
 
-			return c.Status(fiber.StatusOK).JSON(fiber.Map{
-				"cvs": cvs,
+		if err := c.BodyParser(&request); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
 			})
-		})
-	*/
-
-	api.Post("/cv/skills", graduateOnlyMiddleware, func(c *fiber.Ctx) error {
-		skill := SkillsTree{}
+		}
 
-		if err := c.BodyParser(&skill); err != nil {
-			fmt.Println("[POST /cv/skills] Error :", err.Error())
+		skill, err := mergeSkills(skillId, request.DuplicateId)
+		if err != nil {
+			fmt.Println("[POST /admin/skills/:id/merge] ", err.Error())
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
-		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
-		if skill.CVId <= 0 {
-			cv := CurriculumVitae{}
-			err := gormDB.Where("graduate_id = ?", passport.Id).First(&cv).Error
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"skill": skill,
+		})
+	})
 
-			if err != nil {
-				fmt.Println("DB error while searching for user CV: ", err.Error())
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"message": err.Error(),
-				})
-			}
+	admin.Get("/audit", func(c *fiber.Ctx) error {
+		entries := []AuditEntry{}
+		query := gormDB.Model(&AuditEntry{}).Order("id DESC")
+
+		if event := c.Query("event"); event != "" {
+			query = query.Where("event = ?", event)
+		}
 
-			skill.CVId = cv.Id
+		if userId := c.QueryInt("user_id"); userId > 0 {
+			query = query.Where("user_id = ?", userId)
 		}
 
-		err := saveSkillsTreeToDB(DB, skill)
+		err := query.Limit(c.QueryInt("limit", 100)).Find(&entries).Error
 		if err != nil {
-			fmt.Println("[POST /cv/skills] Error :", err.Error())
+			fmt.Println("[GET /admin/audit] ", err.Error())
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"skill": skill,
+			"entries": entries,
 		})
 	})
 
-	api.Get("/skills", func(c *fiber.Ctx) error {
-		skills := []JobSkill{}
+	admin.Get("/outbox", func(c *fiber.Ctx) error {
+		emails := []OutboxEmail{}
+		query := gormDB.Model(&OutboxEmail{}).Order("id DESC")
 
-		err := gormDB.Find(&skills).Error
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
 
+		err := query.Limit(c.QueryInt("limit", 100)).Find(&emails).Error
 		if err != nil {
-			fmt.Println("[Error while fetching job_skills from db] ", err.Error())
+			fmt.Println("[GET /admin/outbox] ", err.Error())
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"message": err.Error(),
 			})
 		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"skills": skills,
+			"emails": emails,
 		})
 	})
 
-	api.Post("/skills", func(c *fiber.Ctx) error {
-		skill := JobSkill{}
-
-		if err := c.BodyParser(&skill); err != nil {
-			fmt.Println("[POST /skills] request parsing error : ", err.Error())
-			return c.SendStatus(fiber.StatusBadRequest)
-		}
+	admin.Get("/outbox/:email_id<int>", func(c *fiber.Ctx) error {
+		email := OutboxEmail{}
 
-		err := gormDB.Create(&skill).Error
-		// err := saveSkillsToDB(DB, skill)
+		err := gormDB.Preload("History").Where("id = ?", c.Params("email_id")).First(&email).Error
 		if err != nil {
-			return c.SendStatus(fiber.StatusInternalServerError)
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"message": "Email not found in the outbox",
+			})
 		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"skill": skill,
+			"email": email,
 		})
 	})
 
-	api.Get("/job_roles", func(c *fiber.Ctx) error {
-		roles := []JobRole{}
-
-		err := gormDB.Find(&roles).Error
-		// roles, err := getJobRoleFromDB(DB)
+	admin.Post("/outbox/:email_id<int>/replay", func(c *fiber.Ctx) error {
+		emailId, _ := strconv.Atoi(c.Params("email_id"))
 
+		email, err := replayOutboxEmail(emailId)
 		if err != nil {
-			fmt.Println("[GET /job_roles] ", err.Error())
-			return c.SendStatus(fiber.StatusInternalServerError)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"message": err.Error(),
+			})
 		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"job_roles": roles,
+			"email": email,
 		})
 	})
 
-	api.Post("/job_roles", func(c *fiber.Ctx) error {
-		role := JobRole{}
-
-		if err := c.BodyParser(&role); err != nil {
-			fmt.Println("[POST /job_roles] ", err.Error())
-			return c.SendStatus(fiber.StatusBadRequest)
-		}
-
-		err := gormDB.Create(&role).Error
-		// err := saveJobRoleToDB(DB, role)
+	admin.Post("/digest/jobs", func(c *fiber.Ctx) error {
+		sent, err := sendJobMatchDigests()
 		if err != nil {
-			fmt.Println("[POST /job_roles] ", err.Error())
-			return c.SendStatus(fiber.StatusInternalServerError)
+			fmt.Println("[POST /admin/digest/jobs] ", err.Error())
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"message": err.Error(),
+			})
 		}
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
-			"job_role": role,
+			"sent": sent,
 		})
 	})
-
 }
 
 func hideSensitiveFriendshipData(friends *[]Friendship) {
@@ -1107,6 +2798,22 @@ func hideSensitiveFriendshipData(friends *[]Friendship) {
 	}
 }
 
+func hideSensitiveApplicationData(applications *[]JobApplication) {
+	for key, _ := range *applications {
+		application := &(*applications)[key]
+
+		application.hideSensitiveData()
+	}
+}
+
+func hideSensitiveCVData(cvs *[]CurriculumVitae) {
+	for key, _ := range *cvs {
+		cv := &(*cvs)[key]
+
+		cv.hideSensitiveData()
+	}
+}
+
 func hideSensitiveUserData(users *[]User) {
 	for key, _ := range *users {
 		user := &(*users)[key]
@@ -1188,24 +2895,32 @@ func jwtMiddlewareProtect(c *fiber.Ctx) error {
 	token_string := extractTokenFromAuthHeader(c)
 	fmt.Printf("Token String = %v \n", token_string)
 
-	type CustomClaims struct {
-		jwt.RegisteredClaims
-		Passport UserPassport `json:"passport"`
-	}
+	claims := &UserClaims{}
+	token, err := jwt.ParseWithClaims(token_string, claims, jwtKeyFunc,
+		jwt.WithExpirationRequired(),
+		jwt.WithValidMethods(getSigningMethodNames()),
+	)
 
-	// claims := jwt.MapClaims{}
-	claims := &CustomClaims{}
-	token, err := jwt.ParseWithClaims(token_string, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret_key), nil
-	})
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Token expired",
+		})
+	}
 
 	if err != nil || !token.Valid {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"message": err.Error(),
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Invalid token",
+		})
+	}
+
+	if isSessionRevoked(claims.SessionId) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"message": errSessionRevoked.Error(),
 		})
 	}
 
 	c.Locals("user_passport", claims.Passport)
+	c.Locals("session_id", claims.SessionId)
 
 	return c.Next()
 }
@@ -1231,104 +2946,17 @@ func extractTokenFromAuthHeader(c *fiber.Ctx) string {
 	return token_string
 }
 
-func sendGmailNotification(emailReceiver string, username string, userpass string) (err error) {
-	password := env["GMAIL_PASSWORD"]
-	sender := env["GMAIL_ACCOUNT"]
-	// password := env["YAHOO_PASSWORD"]
-	// sender := env["YAHOO_ACCOUNT"]
-	receiver := []string{emailReceiver}
-
-	subject := "Registration to Job Platform for Graduate Complete"
-	body := "We are happy to count you in ! This Platform is a thriving community." +
-		" for quickstarting your carreer" + "\r\n Here are your credentials:\r\n"
-
-	message := []byte(
-		"To: " + receiver[0] +
-			"\r\nSubject: " + subject +
-			"\r\n\r\n" + body +
-			"\r\n" + "Username: " + username +
-			"\r\n" + "Password: " + userpass,
-	)
-
-	host := "smtp.gmail.com"
-	port := "587"
-	// host := "smtp.mail.yahoo.com"
-	// port := "465"
-	smtpServer := host + ":" + port
-
-	auth := smtp.PlainAuth("", sender, password, host)
-	err = smtp.SendMail(smtpServer, auth, sender, receiver, message)
-
-	if err != nil {
-		log.Println("Failed to send the email notification. Error : ", err.Error())
-	}
-
-	return err
-}
-
 // ================================================================================================
 // ================================================================================================
 // ==================== Manual Interaction with the DB using standard lib =========================
 // ================================================================================================
 // ================================================================================================
 
-func saveJobSkillsToDB(db *sql.DB, job_id int, skill_id int) (err error) {
-	var sqlStmt string = `
-  INSERT INTO job_skills_tree (job_id, job_skill_id)
-  VALUES (?, ?);
-  `
-
-	_, err = db.Exec(sqlStmt, job_id, skill_id)
-	if err != nil {
-		log.Println(err.Error(), " ---> ", sqlStmt)
-	}
-
-	return err
-}
-
-func saveSkillsTreeToDB(db *sql.DB, tree SkillsTree) (err error) {
-	var sqlStmt string = `
-    INSERT INTO graduate_skills_tree (curriculum_vitae_id, job_skill_id)
-    VALUES (?, ?);
-  `
-	_, err = db.Exec(sqlStmt, tree.CVId, tree.JobSkillId)
-	if err != nil {
-		log.Println(err.Error(), " ---> ", sqlStmt)
-	}
-
-	return err
-}
-
 func filterJobsByElligibility(userCv CurriculumVitae, availableJobs []Job) []Job {
 	filteredJobs := []Job{}
-	points := 0.0
-
-	for _, job := range availableJobs {
-		points = 0.0
-
-		if userCv.Gpa > 2.5 {
-			points += (userCv.Gpa - 2.5) * 10
-		}
-
-		if userCv.JobRoleId == job.RoleId {
-			points += 10
-			points += userCv.Yoe * 5
-		}
-
-		for _, jobSkill := range job.Tree {
-			for _, cvSkill := range userCv.Tree {
-				if jobSkill.Id == cvSkill.Id {
-					points += 3
-					fmt.Println("Gained points for jobskill: ", jobSkill)
-				}
-			}
-
-		}
 
-		if points >= 15.0 {
-			filteredJobs = append(filteredJobs, job)
-		}
-		fmt.Println("filber job ? Job = ", job, " ---> points = ", points)
+	for _, match := range jobScorer.MatchJobs(userCv, availableJobs) {
+		filteredJobs = append(filteredJobs, match.Job)
 	}
 
 	return filteredJobs
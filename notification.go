@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ================================ Email Notifications ===========================================
+// ================================================================================================
+// ================================================================================================
+//
+// A failure to notify is never a reason to fail the request that triggered it, errors are only logged
+
+const messagePreviewLength int = 200
+
+func notifyApplicationReceived(application JobApplication) {
+	graduate, err := findUserById(application.GraduateId)
+	if err != nil {
+		fmt.Println("[Notification] application received: ", err.Error())
+		return
+	}
+
+	job := Job{}
+	if err = gormDB.Where("id = ?", application.JobId).First(&job).Error; err != nil {
+		fmt.Println("[Notification] application received: ", err.Error())
+		return
+	}
+
+	data := ApplicationReceivedEmailData{
+		Username: graduate.Username,
+		JobTitle: job.Title,
+	}
+
+	sendTemplatedEmail(graduate.Email, EMAIL_APPLICATION_RECEIVED, data)
+}
+
+// Graduates are told about every decision of the employer, not when their application is merely viewed
+func notifyApplicationStatus(application JobApplication, note string) {
+	if application.Status == APPLICATION_VIEWED {
+		return
+	}
+
+	graduate, err := findUserById(application.GraduateId)
+	if err != nil {
+		fmt.Println("[Notification] application status: ", err.Error())
+		return
+	}
+
+	data := ApplicationStatusEmailData{
+		Username: graduate.Username,
+		JobTitle: application.Job.Title,
+		Status:   application.Status,
+		Note:     note,
+	}
+
+	sendTemplatedEmail(graduate.Email, EMAIL_APPLICATION_STATUS, data)
+}
+
+func notifyNewMessage(message Message) {
+	sender, err := findUserById(message.SenderId)
+	if err != nil {
+		fmt.Println("[Notification] new message: ", err.Error())
+		return
+	}
+
+	receiver, err := findUserById(message.ReceiverId)
+	if err != nil {
+		fmt.Println("[Notification] new message: ", err.Error())
+		return
+	}
+
+	preview := []rune(message.Message)
+	if len(preview) > messagePreviewLength {
+		preview = append(preview[:messagePreviewLength], []rune("...")...)
+	}
+
+	data := NewMessageEmailData{
+		Username:   receiver.Username,
+		SenderName: sender.Username,
+		Preview:    string(preview),
+	}
+
+	sendTemplatedEmail(receiver.Email, EMAIL_NEW_MESSAGE, data)
+}
+
+// Email every graduate the list of open jobs matching their CV. Graduates without any match are skipped
+func sendJobMatchDigests() (sent int, err error) {
+	cvs := []CurriculumVitae{}
+	err = gormDB.
+		Preload("Graduate").
+		Preload("JobRole").
+		Scopes(preloadSkills).
+		Find(&cvs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	jobs := []Job{}
+	err = gormDB.
+		Where("status = ?", JOB_PUBLISHED).
+		Preload("Role").
+		Scopes(preloadSkills).
+		Find(&jobs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for _, cv := range cvs {
+		if cv.Graduate.Email == "" || cv.Graduate.Suspended {
+			continue
+		}
+
+		matchingJobs := filterJobsByElligibility(cv, jobs)
+		if len(matchingJobs) == 0 {
+			continue
+		}
+
+		data := JobMatchDigestEmailData{
+			Username: cv.Graduate.Username,
+			Jobs:     matchingJobs,
+			Link:     getFrontendURL(),
+		}
+
+		if sendTemplatedEmail(cv.Graduate.Email, EMAIL_JOB_MATCH_DIGEST, data) == nil {
+			sent++
+		}
+	}
+
+	return sent, nil
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ==================================== Email Outbox ==============================================
+// ================================================================================================
+// ================================================================================================
+//
+// Emails are never sent from the request handler. They are saved in the 'outbox_emails' table,
+// then a pool of background workers send them, retrying with an exponential backoff.
+// An email that fails too many times (or with a permanent SMTP error) is dead-lettered.
+// Emails carry live links (password reset, verification, invitations) : their content is erased
+// as soon as they are sent, only the envelope and history are kept. Dead-lettered emails keep it
+// for a week so that an admin can replay them, then it is erased as well.
+//
+//	MAIL_WORKERS=2          # number of workers sending emails concurrently
+//	MAIL_MAX_ATTEMPTS=6     # attempts before an email is dead-lettered
+
+const (
+	OUTBOX_PENDING string = "pending"
+	OUTBOX_SENDING string = "sending"
+	OUTBOX_SENT    string = "sent"
+	OUTBOX_DEAD    string = "dead"
+)
+
+const (
+	outboxPollInterval   time.Duration = 10 * time.Second
+	outboxBaseRetryDelay time.Duration = 30 * time.Second
+	outboxMaxRetryDelay  time.Duration = time.Hour
+	outboxBatchSize      int           = 50
+	outboxDeadRetention  time.Duration = 7 * 24 * time.Hour
+	outboxEraseInterval  time.Duration = time.Hour
+)
+
+type OutboxEmail struct {
+	Id            int             `json:"id"`
+	Receivers     string          `json:"receivers"` // Comma separated
+	Subject       string          `json:"subject"`
+	Body          string          `json:"-"`
+	HTMLBody      string          `json:"-"`
+	Template      string          `json:"template"`
+	Status        string          `json:"status" gorm:"index;default:pending"`
+	Attempts      int             `json:"attempts"`
+	MaxAttempts   int             `json:"max_attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error"`
+	SentAt        *time.Time      `json:"sent_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	History       []OutboxAttempt `json:"history,omitempty" gorm:"foreignKey:OutboxEmailId"`
+}
+
+type OutboxAttempt struct {
+	Id            int       `json:"id"`
+	OutboxEmailId int       `json:"outbox_email_id" gorm:"index"`
+	Success       bool      `json:"success"`
+	Error         string    `json:"error"`
+	AttemptedAt   time.Time `json:"attempted_at"`
+}
+
+func (e OutboxEmail) toMailMessage() MailMessage {
+	return MailMessage{
+		To:       strings.Split(e.Receivers, ","),
+		Subject:  e.Subject,
+		Body:     e.Body,
+		HTMLBody: e.HTMLBody,
+	}
+}
+
+var outboxWakeUp chan struct{} = make(chan struct{}, 1)
+
+func enqueueEmail(message MailMessage, templateName string) error {
+	maxAttempts, err := strconv.Atoi(env["MAIL_MAX_ATTEMPTS"])
+	if err != nil || maxAttempts <= 0 {
+		maxAttempts = 6
+	}
+
+	email := OutboxEmail{
+		Receivers:     strings.Join(message.To, ","),
+		Subject:       message.Subject,
+		Body:          message.Body,
+		HTMLBody:      message.HTMLBody,
+		Template:      templateName,
+		Status:        OUTBOX_PENDING,
+		MaxAttempts:   maxAttempts,
+		NextAttemptAt: time.Now(),
+	}
+
+	if err = gormDB.Create(&email).Error; err != nil {
+		return err
+	}
+
+	wakeUpOutboxWorkers()
+	return nil
+}
+
+// Non blocking, if the dispatcher is already awake it will pick up the new email anyway
+func wakeUpOutboxWorkers() {
+	select {
+	case outboxWakeUp <- struct{}{}:
+	default:
+	}
+}
+
+func startOutboxWorkers(workerCount int) {
+	// Emails claimed by a worker when the server stopped would be stuck forever otherwise
+	err := gormDB.Model(&OutboxEmail{}).
+		Where("status = ?", OUTBOX_SENDING).
+		Update("status", OUTBOX_PENDING).
+		Error
+	if err != nil {
+		fmt.Println("[Outbox] unable to release emails stuck in sending state: ", err.Error())
+	}
+
+	go func() {
+		ticker := time.NewTicker(outboxEraseInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := eraseDeliveredOutboxContent(); err != nil {
+				fmt.Println("[Outbox] unable to erase the content of delivered emails: ", err.Error())
+			}
+
+			<-ticker.C
+		}
+	}()
+
+	queue := make(chan OutboxEmail)
+
+	for i := 0; i < workerCount; i++ {
+		go func() {
+			for email := range queue {
+				processOutboxEmail(email)
+			}
+		}()
+	}
+
+	go func() {
+		ticker := time.NewTicker(outboxPollInterval)
+		defer ticker.Stop()
+
+		for {
+			dispatchDueEmails(queue)
+
+			select {
+			case <-ticker.C:
+			case <-outboxWakeUp:
+			}
+		}
+	}()
+}
+
+func dispatchDueEmails(queue chan<- OutboxEmail) {
+	emails := []OutboxEmail{}
+	err := gormDB.
+		Where("status = ?", OUTBOX_PENDING).
+		Order("next_attempt_at").
+		Limit(outboxBatchSize).
+		Find(&emails).Error
+	if err != nil {
+		fmt.Println("[Outbox] unable to load pending emails: ", err.Error())
+		return
+	}
+
+	now := time.Now()
+
+	for _, email := range emails {
+		if email.NextAttemptAt.After(now) {
+			continue
+		}
+
+		// Claim the email, so that it is never handed to two workers
+		result := gormDB.Model(&OutboxEmail{}).
+			Where("id = ? AND status = ?", email.Id, OUTBOX_PENDING).
+			Update("status", OUTBOX_SENDING)
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		queue <- email
+	}
+}
+
+func processOutboxEmail(email OutboxEmail) {
+	err := mailer.Send(email.toMailMessage())
+	now := time.Now()
+
+	attempt := OutboxAttempt{
+		OutboxEmailId: email.Id,
+		Success:       err == nil,
+		AttemptedAt:   now,
+	}
+
+	email.Attempts++
+
+	switch {
+	case err == nil:
+		email.Status = OUTBOX_SENT
+		email.SentAt = &now
+		email.LastError = ""
+
+	case isPermanentMailError(err) || email.Attempts >= email.MaxAttempts:
+		attempt.Error = err.Error()
+		email.Status = OUTBOX_DEAD
+		email.LastError = err.Error()
+		fmt.Println("[Outbox] email ", email.Id, " dead-lettered after ", email.Attempts, " attempt(s): ", err.Error())
+
+	default:
+		attempt.Error = err.Error()
+		email.Status = OUTBOX_PENDING
+		email.LastError = err.Error()
+		email.NextAttemptAt = now.Add(outboxRetryDelay(email.Attempts))
+	}
+
+	if err := gormDB.Create(&attempt).Error; err != nil {
+		fmt.Println("[Outbox] unable to save attempt history: ", err.Error())
+	}
+
+	changes := map[string]interface{}{
+		"status":          email.Status,
+		"attempts":        email.Attempts,
+		"last_error":      email.LastError,
+		"next_attempt_at": email.NextAttemptAt,
+		"sent_at":         email.SentAt,
+	}
+
+	if email.Status == OUTBOX_SENT {
+		changes["body"] = ""
+		changes["html_body"] = ""
+	}
+
+	err = gormDB.Model(&OutboxEmail{}).
+		Where("id = ?", email.Id).
+		Updates(changes).Error
+	if err != nil {
+		fmt.Println("[Outbox] unable to update email ", email.Id, ": ", err.Error())
+	}
+}
+
+// 30s, 1min, 2min, 4min ... capped to 1 hour
+func outboxRetryDelay(attempts int) time.Duration {
+	delay := outboxBaseRetryDelay
+
+	for i := 1; i < attempts && delay < outboxMaxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > outboxMaxRetryDelay {
+		delay = outboxMaxRetryDelay
+	}
+
+	return delay
+}
+
+// SMTP 5xx replies (unknown mailbox, rejected content ...) won't succeed on retry
+func isPermanentMailError(err error) bool {
+	if errors.Is(err, errMalformedEmail) {
+		return true
+	}
+
+	var smtpError *textproto.Error
+
+	if errors.As(err, &smtpError) {
+		return smtpError.Code >= 500
+	}
+
+	return false
+}
+
+// Give a dead (or pending) email a fresh set of attempts. Its history is kept
+func replayOutboxEmail(emailId int) (OutboxEmail, error) {
+	email := OutboxEmail{}
+
+	err := gormDB.Where("id = ?", emailId).First(&email).Error
+	if err != nil {
+		return email, fmt.Errorf("Email not found in the outbox")
+	}
+
+	if email.Status == OUTBOX_SENT || email.Status == OUTBOX_SENDING {
+		return email, fmt.Errorf("Only pending or dead emails can be replayed, this one is '%s'", email.Status)
+	}
+
+	if email.Body == "" && email.HTMLBody == "" {
+		return email, fmt.Errorf("The content of this email was erased %d days after it was dead-lettered, it can't be replayed", int(outboxDeadRetention.Hours()/24))
+	}
+
+	err = gormDB.Model(&OutboxEmail{}).
+		Where("id = ?", email.Id).
+		Updates(map[string]interface{}{
+			"status":          OUTBOX_PENDING,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		}).Error
+	if err != nil {
+		return email, err
+	}
+
+	wakeUpOutboxWorkers()
+
+	err = gormDB.Preload("History").Where("id = ?", email.Id).First(&email).Error
+	return email, err
+}
+
+// Sent emails saved before their content was erased, and dead emails no longer replayed after the retention
+func eraseDeliveredOutboxContent() error {
+	return gormDB.Model(&OutboxEmail{}).
+		Where("(status = ? OR (status = ? AND updated_at < ?)) AND (body <> '' OR html_body <> '')", OUTBOX_SENT, OUTBOX_DEAD, time.Now().Add(-outboxDeadRetention)).
+		Updates(map[string]interface{}{"body": "", "html_body": ""}).Error
+}
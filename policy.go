@@ -0,0 +1,164 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ================================== Authorization Policy ========================================
+// ================================================================================================
+// ================================================================================================
+//
+// Role middlewares (graduateOnlyMiddleware, ...) only say who can call a route.
+// Policies below say on which resources : a graduate owns its CV, applications, friendships and messages,
+// an employer manages its jobs (or its company jobs) and the applications made to them. Admins can access everything.
+// A failed policy check is answered with a 403
+
+func forbidden(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"message": message,
+	})
+}
+
+func isSelfOrAdmin(passport UserPassport, userId int) bool {
+	return passport.Admin || passport.Id == userId
+}
+
+// Jobs posted before ownership existed have no employer, only admins can manage them.
+// A company job is managed by the owners and recruiters of the company, whoever posted it
+func canManageJob(passport UserPassport, jobId int) bool {
+	return hasJobAccess(passport, jobId, COMPANY_OWNER, COMPANY_RECRUITER)
+}
+
+// Company viewers can follow the jobs and their applications, without managing them
+func canViewJobApplications(passport UserPassport, jobId int) bool {
+	return hasJobAccess(passport, jobId, COMPANY_OWNER, COMPANY_RECRUITER, COMPANY_VIEWER)
+}
+
+func hasJobAccess(passport UserPassport, jobId int, companyRoles ...string) bool {
+	if passport.Admin {
+		return true
+	}
+
+	if !passport.Employer {
+		return false
+	}
+
+	jobs := []Job{}
+	gormDB.Where("id = ?", jobId).Limit(1).Find(&jobs)
+
+	if len(jobs) == 0 {
+		return false
+	}
+
+	if jobs[0].CompanyId != 0 {
+		return hasCompanyRole(passport, jobs[0].CompanyId, companyRoles...)
+	}
+
+	return jobs[0].EmployerId == passport.Id
+}
+
+func canAccessApplication(passport UserPassport, application JobApplication) bool {
+	if isSelfOrAdmin(passport, application.GraduateId) {
+		return true
+	}
+
+	return canViewJobApplications(passport, application.JobId)
+}
+
+// Files of a graduate are visible to the employers reviewing one of its applications
+func canAccessGraduateFiles(passport UserPassport, graduateId int) bool {
+	if isSelfOrAdmin(passport, graduateId) {
+		return true
+	}
+
+	if !passport.Employer {
+		return false
+	}
+
+	jobIds := []int{}
+	gormDB.Model(&JobApplication{}).Where("graduate_id = ?", graduateId).Pluck("job_id", &jobIds)
+
+	for _, jobId := range jobIds {
+		if canViewJobApplications(passport, jobId) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func canAccessCV(passport UserPassport, cv CurriculumVitae) bool {
+	return isSelfOrAdmin(passport, cv.GraduateId)
+}
+
+func canAccessConversation(passport UserPassport, senderId int, receiverId int) bool {
+	return passport.Admin || passport.Id == senderId || passport.Id == receiverId
+}
+
+// The route parameter 'param' must be the id of the caller. An optional parameter left empty is accepted,
+// the handler then falls back to the caller id
+func selfOnlyMiddleware(param string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+
+		value := c.Params(param)
+		if value == "" {
+			return c.Next()
+		}
+
+		userId, err := strconv.Atoi(value)
+		if err != nil || !isSelfOrAdmin(passport, userId) {
+			return forbidden(c, "Forbidden, you can only access your own data")
+		}
+
+		return c.Next()
+	}
+}
+
+// The caller must be one of the two users of the conversation
+func conversationOnlyMiddleware(firstParam string, secondParam string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+
+		firstId, _ := strconv.Atoi(c.Params(firstParam))
+		secondId, _ := strconv.Atoi(c.Params(secondParam))
+
+		if !canAccessConversation(passport, firstId, secondId) {
+			return forbidden(c, "Forbidden, you are not part of this conversation")
+		}
+
+		return c.Next()
+	}
+}
+
+// The route parameter 'param' must be the id of a job managed by the caller
+func jobManagerOnlyMiddleware(param string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+
+		jobId, err := strconv.Atoi(c.Params(param))
+		if err != nil || !canManageJob(passport, jobId) {
+			return forbidden(c, "Forbidden, you can only manage your own jobs")
+		}
+
+		return c.Next()
+	}
+}
+
+// The route parameter 'param' must be the id of a job whose applications the caller can review
+func jobViewerOnlyMiddleware(param string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var passport UserPassport = getUserPassportFromMiddlewareContext(c)
+
+		jobId, err := strconv.Atoi(c.Params(param))
+		if err != nil || !canViewJobApplications(passport, jobId) {
+			return forbidden(c, "Forbidden, you can only access the applications of your own jobs")
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ===================================== Rate Limiting ============================================
+// ================================================================================================
+// ================================================================================================
+//
+// Fixed window counters, keyed by route and client (IP or username).
+// The counters live in memory by default. With several server processes, they must be shared :
+//
+//	RATE_LIMIT_STORE=memory | sqlite
+
+type RateLimitStore interface {
+	// Count one more hit for the key, and return the number of hits in the current window
+	Hit(key string, window time.Duration) (count int, resetAt time.Time, err error)
+}
+
+var rateLimitStore RateLimitStore = newMemoryRateLimitStore()
+
+func newRateLimitStoreFromEnv(env map[string]string) (RateLimitStore, error) {
+	switch env["RATE_LIMIT_STORE"] {
+	case "", "memory":
+		return newMemoryRateLimitStore(), nil
+
+	case "sqlite":
+		return &SQLiteRateLimitStore{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown RATE_LIMIT_STORE '%s', expected one of: memory, sqlite", env["RATE_LIMIT_STORE"])
+}
+
+// Reject the request with a 429 once the client exceeded 'limit' requests during 'window'.
+// Requests for which 'keyFunc' returns an empty key are not counted
+func rateLimitMiddleware(name string, limit int, window time.Duration, keyFunc func(c *fiber.Ctx) string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := keyFunc(c)
+		if key == "" {
+			return c.Next()
+		}
+
+		count, resetAt, err := rateLimitStore.Hit(name+":"+key, window)
+		if err != nil {
+			// Better to let the request through than to lock everyone out because of a storage issue
+			fmt.Println("[Rate limit] ", err.Error())
+			return c.Next()
+		}
+
+		c.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Set("X-RateLimit-Remaining", strconv.Itoa(max(limit-count, 0)))
+
+		if count > limit {
+			retryAfter := int(time.Until(resetAt).Seconds()) + 1
+			c.Set("Retry-After", strconv.Itoa(retryAfter))
+
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"message": "Too many requests, try again in " + strconv.Itoa(retryAfter) + " seconds",
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+func rateLimitKeyByIP(c *fiber.Ctx) string {
+	return c.IP()
+}
+
+func rateLimitKeyByUsername(c *fiber.Ctx) string {
+	userCredential := UserCredential{}
+
+	if err := c.BodyParser(&userCredential); err != nil {
+		return ""
+	}
+
+	return userCredential.Username
+}
+
+// ==================================================
+//                  In Memory
+// ==================================================
+
+type rateLimitCounter struct {
+	count   int
+	resetAt time.Time
+}
+
+type MemoryRateLimitStore struct {
+	mutex     sync.Mutex
+	counters  map[string]*rateLimitCounter
+	lastSweep time.Time
+}
+
+func newMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		counters:  map[string]*rateLimitCounter{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *MemoryRateLimitStore) Hit(key string, window time.Duration) (int, time.Time, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+
+	// Forget expired counters from time to time, otherwise the map would only grow
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, counter := range s.counters {
+			if now.After(counter.resetAt) {
+				delete(s.counters, k)
+			}
+		}
+
+		s.lastSweep = now
+	}
+
+	counter, ok := s.counters[key]
+	if !ok || now.After(counter.resetAt) {
+		counter = &rateLimitCounter{resetAt: now.Add(window)}
+		s.counters[key] = counter
+	}
+
+	counter.count++
+
+	return counter.count, counter.resetAt, nil
+}
+
+// ==================================================
+//                  SQLite
+// ==================================================
+
+// Window end is saved as a unix timestamp, so that the comparison is done by SQLite in a single statement
+type RateLimitCounter struct {
+	Key     string `gorm:"primaryKey"`
+	Count   int
+	ResetAt int64
+}
+
+type SQLiteRateLimitStore struct{}
+
+func (s *SQLiteRateLimitStore) Hit(key string, window time.Duration) (int, time.Time, error) {
+	now := time.Now()
+	resetAt := now.Add(window).Unix()
+
+	var sqlStmt string = `
+    INSERT INTO rate_limit_counters (key, count, reset_at) VALUES (?, 1, ?)
+    ON CONFLICT (key) DO UPDATE SET
+      count = CASE WHEN reset_at <= ? THEN 1 ELSE count + 1 END,
+      reset_at = CASE WHEN reset_at <= ? THEN excluded.reset_at ELSE reset_at END;
+  `
+
+	err := gormDB.Exec(sqlStmt, key, resetAt, now.Unix(), now.Unix()).Error
+	if err != nil {
+		return 0, now, err
+	}
+
+	counter := RateLimitCounter{}
+	err = gormDB.Where("key = ?", key).First(&counter).Error
+	if err != nil {
+		return 0, now, err
+	}
+
+	return counter.Count, time.Unix(counter.ResetAt, 0), nil
+}
+
+// ================================================================================================
+// ================================================================================================
+// ==================================== Account Lockout ===========================================
+// ================================================================================================
+// ================================================================================================
+
+const (
+	maxFailedLoginAttempts int           = 5
+	accountLockoutDuration time.Duration = 15 * time.Minute
+)
+
+func isAccountLocked(user User) bool {
+	return user.LockedUntil != nil && time.Now().Before(*user.LockedUntil)
+}
+
+// Count a wrong password. The account is locked once too many happened in a row.
+// The counter is incremented by the database, concurrent failures can't overwrite each other
+func recordFailedLogin(user User, ip string) {
+	err := gormDB.Model(&User{}).
+		Where("id = ?", user.Id).
+		Update("failed_login_attempts", gorm.Expr("failed_login_attempts + 1")).Error
+	if err != nil {
+		fmt.Println("[Lockout] unable to record failed login: ", err.Error())
+		return
+	}
+
+	var attempts int
+	gormDB.Model(&User{}).Where("id = ?", user.Id).Pluck("failed_login_attempts", &attempts)
+
+	if attempts < maxFailedLoginAttempts {
+		return
+	}
+
+	lockedUntil := time.Now().Add(accountLockoutDuration)
+
+	// Only the request that actually resets the counter locks the account and audits it
+	result := gormDB.Model(&User{}).
+		Where("id = ? AND failed_login_attempts >= ?", user.Id, maxFailedLoginAttempts).
+		Updates(map[string]interface{}{"failed_login_attempts": 0, "locked_until": lockedUntil})
+	if result.Error != nil {
+		fmt.Println("[Lockout] unable to lock account: ", result.Error.Error())
+		return
+	}
+
+	if result.RowsAffected > 0 {
+		details := fmt.Sprintf("%d failed login attempts, locked until %s", attempts, lockedUntil.Format(time.RFC3339))
+		recordAuditEvent(AUDIT_ACCOUNT_LOCKED, user, ip, details)
+	}
+}
+
+func resetFailedLogins(user User) {
+	if user.FailedLoginAttempts == 0 && user.LockedUntil == nil {
+		return
+	}
+
+	err := gormDB.Model(&User{}).
+		Where("id = ?", user.Id).
+		Updates(map[string]interface{}{"failed_login_attempts": 0, "locked_until": nil}).
+		Error
+	if err != nil {
+		fmt.Println("[Lockout] unable to reset failed logins: ", err.Error())
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ====================================== Job Matching ============================================
+// ================================================================================================
+// ================================================================================================
+//
+// A graduate CV is scored against every job. Jobs reaching the threshold are recommended,
+// best scores first, with the points earned by each factor. Weights are read from the .env file :
+//
+//	SCORE_GPA_BASELINE=2.5   # GPA above which points are earned
+//	SCORE_GPA_WEIGHT=10      # points per GPA point above the baseline
+//	SCORE_ROLE_MATCH=10      # points when the CV targets the job role
+//	SCORE_YOE_WEIGHT=5       # points per year of experience, only when the role matches
+//	SCORE_SKILL_MATCH=3      # points per job skill listed on the CV, half when below the expected level
+//	SCORE_RELATED_SKILL=1    # points per missing job skill for which the CV lists a related skill
+//	SCORE_THRESHOLD=15       # minimum score of a recommended job
+//
+// A CV missing a skill the job requires never matches it, whatever its score.
+
+const (
+	SCORE_FACTOR_GPA        string = "gpa"
+	SCORE_FACTOR_ROLE       string = "role"
+	SCORE_FACTOR_EXPERIENCE string = "experience"
+	SCORE_FACTOR_SKILL      string = "skill"
+	SCORE_FACTOR_RELATED    string = "related_skill"
+)
+
+type ScoringWeights struct {
+	GpaBaseline  float64 `json:"gpa_baseline"`
+	Gpa          float64 `json:"gpa"`
+	RoleMatch    float64 `json:"role_match"`
+	Yoe          float64 `json:"yoe"`
+	SkillMatch   float64 `json:"skill_match"`
+	SkillRelated float64 `json:"skill_related"`
+	Threshold    float64 `json:"threshold"`
+}
+
+var defaultScoringWeights ScoringWeights = ScoringWeights{
+	GpaBaseline:  2.5,
+	Gpa:          10,
+	RoleMatch:    10,
+	Yoe:          5,
+	SkillMatch:   3,
+	SkillRelated: 1,
+	Threshold:    15,
+}
+
+type Scorer struct {
+	Weights ScoringWeights
+}
+
+var jobScorer *Scorer = &Scorer{Weights: defaultScoringWeights}
+
+// Points earned by one factor, e.g. {skill, Docker, 3}
+type ScoreFactor struct {
+	Factor string  `json:"factor"`
+	Label  string  `json:"label"`
+	Points float64 `json:"points"`
+}
+
+func (f ScoreFactor) String() string {
+	return fmt.Sprintf("%s %+g", f.Label, f.Points)
+}
+
+// The job fields are inlined, a match reads like a job with its score
+type JobMatch struct {
+	Job
+	Score           float64       `json:"score"`
+	Breakdown       []ScoreFactor `json:"breakdown"`
+	Summary         string        `json:"summary"`
+	Disqualified    bool          `json:"disqualified"`
+	MissingRequired []JobSkill    `json:"missing_required"`
+}
+
+func newScorerFromEnv(env map[string]string) (*Scorer, error) {
+	weights := defaultScoringWeights
+
+	settings := []struct {
+		key    string
+		weight *float64
+	}{
+		{"SCORE_GPA_BASELINE", &weights.GpaBaseline},
+		{"SCORE_GPA_WEIGHT", &weights.Gpa},
+		{"SCORE_ROLE_MATCH", &weights.RoleMatch},
+		{"SCORE_YOE_WEIGHT", &weights.Yoe},
+		{"SCORE_SKILL_MATCH", &weights.SkillMatch},
+		{"SCORE_RELATED_SKILL", &weights.SkillRelated},
+		{"SCORE_THRESHOLD", &weights.Threshold},
+	}
+
+	for _, setting := range settings {
+		value := strings.TrimSpace(env[setting.key])
+		if value == "" {
+			continue
+		}
+
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) {
+			return nil, fmt.Errorf("%s must be a number, got '%s'", setting.key, value)
+		}
+
+		*setting.weight = parsed
+	}
+
+	return &Scorer{Weights: weights}, nil
+}
+
+// Points are rounded to 2 decimals, (3.7 - 2.5) * 10 is 12 and not 12.000000000000002
+func roundScore(points float64) float64 {
+	return math.Round(points*100) / 100
+}
+
+func (s *Scorer) ScoreJob(cv CurriculumVitae, job Job) JobMatch {
+	match := JobMatch{Job: job, Breakdown: []ScoreFactor{}, MissingRequired: []JobSkill{}}
+
+	add := func(factor string, label string, points float64) {
+		points = roundScore(points)
+		if points == 0 {
+			return
+		}
+
+		match.Breakdown = append(match.Breakdown, ScoreFactor{Factor: factor, Label: label, Points: points})
+		match.Score += points
+	}
+
+	if cv.Gpa > s.Weights.GpaBaseline {
+		add(SCORE_FACTOR_GPA, fmt.Sprintf("GPA %g", cv.Gpa), (cv.Gpa-s.Weights.GpaBaseline)*s.Weights.Gpa)
+	}
+
+	if cv.JobRoleId == job.RoleId {
+		add(SCORE_FACTOR_ROLE, "role match", s.Weights.RoleMatch)
+		add(SCORE_FACTOR_EXPERIENCE, fmt.Sprintf("%g year(s) of experience", cv.Yoe), cv.Yoe*s.Weights.Yoe)
+	}
+
+	for _, requirement := range job.Skills {
+		cvSkill, ok := findCVSkill(cv.Skills, requirement.JobSkillId)
+		if !ok {
+			// A related skill earns partial credit, it doesn't stand in for a required one
+			if requirement.Required {
+				match.Disqualified = true
+				match.MissingRequired = append(match.MissingRequired, requirement.Skill)
+			}
+
+			if related, ok := findRelatedCVSkill(cv.Skills, requirement.Skill); ok {
+				add(SCORE_FACTOR_RELATED, related.Skill.Name+" (related to "+requirement.Skill.Name+")", s.Weights.SkillRelated)
+			}
+
+			continue
+		}
+
+		if requirement.isMetBy(cvSkill) {
+			add(SCORE_FACTOR_SKILL, requirement.Skill.Name, s.Weights.SkillMatch)
+		} else {
+			add(SCORE_FACTOR_SKILL, requirement.Skill.Name+" (below the expected level)", s.Weights.SkillMatch/2)
+		}
+	}
+
+	match.Score = roundScore(match.Score)
+
+	labels := []string{}
+	for _, factor := range match.Breakdown {
+		labels = append(labels, factor.String())
+	}
+	match.Summary = strings.Join(labels, ", ")
+
+	return match
+}
+
+// Score every job and keep the ones reaching the threshold, best scores first
+func (s *Scorer) MatchJobs(cv CurriculumVitae, jobs []Job) []JobMatch {
+	matches := []JobMatch{}
+
+	for _, job := range jobs {
+		match := s.ScoreJob(cv, job)
+
+		if !match.Disqualified && match.Score >= s.Weights.Threshold {
+			matches = append(matches, match)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+
+	return matches
+}
+
+// ==================================================
+//                    Gap Analysis
+// ==================================================
+
+// A skill missing from the CV, or listed below the level the job expects
+type SkillGap struct {
+	Skill         JobSkill `json:"skill"`
+	Required      bool     `json:"required"`
+	Level         string   `json:"level"`
+	Years         float64  `json:"years"`
+	IsMissing     bool     `json:"is_missing"`
+	ScoreIfClosed float64  `json:"score_if_closed"`
+}
+
+type YoeGap struct {
+	Required      float64 `json:"required"`
+	Actual        float64 `json:"actual"`
+	Shortfall     float64 `json:"shortfall"`
+	ScoreIfClosed float64 `json:"score_if_closed"`
+}
+
+type RoleGap struct {
+	Required      JobRole `json:"required"`
+	Actual        JobRole `json:"actual"`
+	ScoreIfClosed float64 `json:"score_if_closed"`
+}
+
+// What keeps a CV from matching a job. Each 'score_if_closed' is the score reached once this gap alone is closed
+type JobGap struct {
+	JobId            int        `json:"job_id"`
+	Score            float64    `json:"score"`
+	Threshold        float64    `json:"threshold"`
+	IsMatch          bool       `json:"is_match"`
+	Disqualified     bool       `json:"disqualified"` // A required skill is missing
+	MissingSkills    []SkillGap `json:"missing_skills"`
+	Yoe              *YoeGap    `json:"yoe"`  // nil when the CV has enough experience
+	Role             *RoleGap   `json:"role"` // nil when the CV targets the job role
+	ScoreIfAllClosed float64    `json:"score_if_all_closed"`
+}
+
+func (s *Scorer) AnalyzeGap(cv CurriculumVitae, job Job) JobGap {
+	match := s.ScoreJob(cv, job)
+
+	gap := JobGap{
+		JobId:         job.Id,
+		Score:         match.Score,
+		Threshold:     s.Weights.Threshold,
+		IsMatch:       !match.Disqualified && match.Score >= s.Weights.Threshold,
+		Disqualified:  match.Disqualified,
+		MissingSkills: []SkillGap{},
+	}
+
+	// Each gap is closed on a copy of the CV, the skills are copied too since closing a skill gap changes them
+	closed := cv
+	closed.Skills = append([]CVSkill{}, cv.Skills...)
+
+	for _, requirement := range job.Skills {
+		cvSkill, ok := findCVSkill(cv.Skills, requirement.JobSkillId)
+		if ok && requirement.isMetBy(cvSkill) {
+			continue
+		}
+
+		expected := CVSkill{
+			CurriculumVitaeId: cv.Id,
+			JobSkillId:        requirement.JobSkillId,
+			Level:             requirement.Level,
+			Years:             requirement.Years,
+			Skill:             requirement.Skill,
+		}
+
+		withSkill := cv
+		withSkill.Skills = replaceCVSkill(cv.Skills, expected)
+
+		gap.MissingSkills = append(gap.MissingSkills, SkillGap{
+			Skill:         requirement.Skill,
+			Required:      requirement.Required,
+			Level:         requirement.Level,
+			Years:         requirement.Years,
+			IsMissing:     !ok,
+			ScoreIfClosed: s.ScoreJob(withSkill, job).Score,
+		})
+
+		closed.Skills = replaceCVSkill(closed.Skills, expected)
+	}
+
+	if cv.Yoe < job.Yoe {
+		withYoe := cv
+		withYoe.Yoe = job.Yoe
+
+		gap.Yoe = &YoeGap{
+			Required:      job.Yoe,
+			Actual:        cv.Yoe,
+			Shortfall:     roundScore(job.Yoe - cv.Yoe),
+			ScoreIfClosed: s.ScoreJob(withYoe, job).Score,
+		}
+
+		closed.Yoe = job.Yoe
+	}
+
+	if cv.JobRoleId != job.RoleId {
+		withRole := cv
+		withRole.JobRoleId = job.RoleId
+
+		gap.Role = &RoleGap{
+			Required:      job.Role,
+			Actual:        cv.JobRole,
+			ScoreIfClosed: s.ScoreJob(withRole, job).Score,
+		}
+
+		closed.JobRoleId = job.RoleId
+	}
+
+	gap.ScoreIfAllClosed = s.ScoreJob(closed, job).Score
+
+	return gap
+}
+
+// Copy of 'skills' where 'skill' is listed, replacing the entry of the same skill if any
+func replaceCVSkill(skills []CVSkill, skill CVSkill) []CVSkill {
+	replaced := []CVSkill{}
+
+	for _, current := range skills {
+		if current.JobSkillId != skill.JobSkillId {
+			replaced = append(replaced, current)
+		}
+	}
+
+	return append(replaced, skill)
+}
+
+// ==================================================
+//                 Candidate Ranking
+// ==================================================
+
+// What an employer sees of a candidate CV. The email is only given once the graduate applied to the job,
+// the other graduates are reached through the platform
+type CandidateCV struct {
+	Id         int       `json:"id"`
+	GraduateId int       `json:"graduate_id"`
+	Username   string    `json:"username"`
+	Email      string    `json:"email,omitempty"`
+	Gpa        float64   `json:"gpa"`
+	Yoe        float64   `json:"yoe"`
+	JobRole    JobRole   `json:"job_role"`
+	Skills     []CVSkill `json:"skills"`
+}
+
+type CandidateMatch struct {
+	Cv            CandidateCV   `json:"cv"`
+	Score         float64       `json:"score"`
+	Breakdown     []ScoreFactor `json:"breakdown"`
+	Summary       string        `json:"summary"`
+	HasApplied    bool          `json:"has_applied"`
+	ApplicationId int           `json:"application_id,omitempty"`
+}
+
+func newCandidateCV(cv CurriculumVitae, hasApplied bool) CandidateCV {
+	candidate := CandidateCV{
+		Id:         cv.Id,
+		GraduateId: cv.GraduateId,
+		Username:   cv.Graduate.Username,
+		Gpa:        cv.Gpa,
+		Yoe:        cv.Yoe,
+		JobRole:    cv.JobRole,
+		Skills:     cv.Skills,
+	}
+
+	if hasApplied {
+		candidate.Email = cv.Graduate.Email
+	}
+
+	return candidate
+}
+
+// Score every CV against the job, with the same rules graduates are matched to jobs with, and keep the ones
+// reaching 'minScore' and not disqualified, best scores first. 'applications' maps a graduate to their application
+func (s *Scorer) RankCandidates(job Job, cvs []CurriculumVitae, applications map[int]int, minScore float64) []CandidateMatch {
+	candidates := []CandidateMatch{}
+
+	for _, cv := range cvs {
+		match := s.ScoreJob(cv, job)
+		if match.Disqualified || match.Score < minScore {
+			continue
+		}
+
+		applicationId, hasApplied := applications[cv.GraduateId]
+
+		candidates = append(candidates, CandidateMatch{
+			Cv:            newCandidateCV(cv, hasApplied),
+			Score:         match.Score,
+			Breakdown:     match.Breakdown,
+			Summary:       match.Summary,
+			HasApplied:    hasApplied,
+			ApplicationId: applicationId,
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	return candidates
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var (
+	testSkillGo         JobSkill = JobSkill{Id: 1, Name: "Go"}
+	testSkillDevOps     JobSkill = JobSkill{Id: 2, Name: "DevOps"}
+	testSkillDocker     JobSkill = JobSkill{Id: 3, Name: "Docker", ParentId: 2}
+	testSkillKubernetes JobSkill = JobSkill{Id: 4, Name: "Kubernetes", ParentId: 2}
+)
+
+// Weights are fixed here, the defaults can change without breaking the tests
+var testScorer *Scorer = &Scorer{Weights: ScoringWeights{
+	GpaBaseline:  2.5,
+	Gpa:          10,
+	RoleMatch:    10,
+	Yoe:          5,
+	SkillMatch:   3,
+	SkillRelated: 1,
+	Threshold:    15,
+}}
+
+func testCVSkill(skill JobSkill, level string, years float64) CVSkill {
+	return CVSkill{CurriculumVitaeId: 1, JobSkillId: skill.Id, Level: level, Years: years, Skill: skill}
+}
+
+func testRequirement(skill JobSkill, required bool, level string, years float64) JobSkillRequirement {
+	return JobSkillRequirement{JobId: 1, JobSkillId: skill.Id, Required: required, Level: level, Years: years, Skill: skill}
+}
+
+func TestScoreJob(t *testing.T) {
+	tests := []struct {
+		name            string
+		cv              CurriculumVitae
+		job             Job
+		score           float64
+		factors         []string
+		disqualified    bool
+		missingRequired []int
+	}{
+		{
+			name:    "nothing in common",
+			cv:      CurriculumVitae{Gpa: 2, JobRoleId: 1, Yoe: 3},
+			job:     Job{RoleId: 2},
+			score:   0,
+			factors: []string{},
+		},
+		{
+			name:    "GPA above the baseline",
+			cv:      CurriculumVitae{Gpa: 3.7, JobRoleId: 1},
+			job:     Job{RoleId: 2},
+			score:   12,
+			factors: []string{SCORE_FACTOR_GPA},
+		},
+		{
+			name:    "experience only counts for the same role",
+			cv:      CurriculumVitae{JobRoleId: 1, Yoe: 2},
+			job:     Job{RoleId: 1},
+			score:   20,
+			factors: []string{SCORE_FACTOR_ROLE, SCORE_FACTOR_EXPERIENCE},
+		},
+		{
+			name: "every factor",
+			cv: CurriculumVitae{Gpa: 3.7, JobRoleId: 1, Yoe: 2, Skills: []CVSkill{
+				testCVSkill(testSkillGo, SKILL_ADVANCED, 3),
+			}},
+			job:     Job{RoleId: 1, Skills: []JobSkillRequirement{testRequirement(testSkillGo, true, SKILL_INTERMEDIATE, 2)}},
+			score:   35,
+			factors: []string{SCORE_FACTOR_GPA, SCORE_FACTOR_ROLE, SCORE_FACTOR_EXPERIENCE, SCORE_FACTOR_SKILL},
+		},
+		{
+			name:    "skill below the expected level",
+			cv:      CurriculumVitae{Skills: []CVSkill{testCVSkill(testSkillGo, SKILL_BEGINNER, 5)}},
+			job:     Job{RoleId: 1, Skills: []JobSkillRequirement{testRequirement(testSkillGo, true, SKILL_ADVANCED, 0)}},
+			score:   1.5,
+			factors: []string{SCORE_FACTOR_SKILL},
+		},
+		{
+			name:    "skill without enough years",
+			cv:      CurriculumVitae{Skills: []CVSkill{testCVSkill(testSkillGo, SKILL_EXPERT, 1)}},
+			job:     Job{RoleId: 1, Skills: []JobSkillRequirement{testRequirement(testSkillGo, false, "", 3)}},
+			score:   1.5,
+			factors: []string{SCORE_FACTOR_SKILL},
+		},
+		{
+			name:            "required skill missing",
+			cv:              CurriculumVitae{Gpa: 4, Skills: []CVSkill{}},
+			job:             Job{RoleId: 1, Skills: []JobSkillRequirement{testRequirement(testSkillGo, true, "", 0)}},
+			score:           15,
+			factors:         []string{SCORE_FACTOR_GPA},
+			disqualified:    true,
+			missingRequired: []int{testSkillGo.Id},
+		},
+		{
+			name:    "sibling skill earns partial credit",
+			cv:      CurriculumVitae{Skills: []CVSkill{testCVSkill(testSkillKubernetes, "", 0)}},
+			job:     Job{RoleId: 1, Skills: []JobSkillRequirement{testRequirement(testSkillDocker, false, "", 0)}},
+			score:   1,
+			factors: []string{SCORE_FACTOR_RELATED},
+		},
+		{
+			name:    "parent skill earns partial credit",
+			cv:      CurriculumVitae{Skills: []CVSkill{testCVSkill(testSkillDevOps, "", 0)}},
+			job:     Job{RoleId: 1, Skills: []JobSkillRequirement{testRequirement(testSkillDocker, false, "", 0)}},
+			score:   1,
+			factors: []string{SCORE_FACTOR_RELATED},
+		},
+		{
+			name:            "related skill doesn't replace a required one",
+			cv:              CurriculumVitae{Skills: []CVSkill{testCVSkill(testSkillKubernetes, "", 0)}},
+			job:             Job{RoleId: 1, Skills: []JobSkillRequirement{testRequirement(testSkillDocker, true, "", 0)}},
+			score:           1,
+			factors:         []string{SCORE_FACTOR_RELATED},
+			disqualified:    true,
+			missingRequired: []int{testSkillDocker.Id},
+		},
+	}
+
+	for _, test := range tests {
+		match := testScorer.ScoreJob(test.cv, test.job)
+
+		if match.Score != test.score {
+			t.Errorf("%s: score is %g, expected %g (%s)", test.name, match.Score, test.score, match.Summary)
+		}
+
+		factors := []string{}
+		for _, factor := range match.Breakdown {
+			factors = append(factors, factor.Factor)
+		}
+
+		if !reflect.DeepEqual(factors, test.factors) {
+			t.Errorf("%s: factors are %v, expected %v", test.name, factors, test.factors)
+		}
+
+		if match.Disqualified != test.disqualified {
+			t.Errorf("%s: disqualified is %v, expected %v", test.name, match.Disqualified, test.disqualified)
+		}
+
+		missingRequired := []int{}
+		for _, skill := range match.MissingRequired {
+			missingRequired = append(missingRequired, skill.Id)
+		}
+
+		if fmt.Sprint(missingRequired) != fmt.Sprint(test.missingRequired) {
+			t.Errorf("%s: missing required skills are %v, expected %v", test.name, missingRequired, test.missingRequired)
+		}
+	}
+}
+
+func TestMatchJobs(t *testing.T) {
+	cv := CurriculumVitae{Gpa: 3, JobRoleId: 1, Yoe: 1, Skills: []CVSkill{testCVSkill(testSkillGo, "", 0)}}
+
+	jobs := []Job{
+		{Id: 1, RoleId: 2},
+		{Id: 2, RoleId: 1, Skills: []JobSkillRequirement{testRequirement(testSkillGo, false, "", 0)}},
+		{Id: 3, RoleId: 1},
+		{Id: 4, RoleId: 1, Skills: []JobSkillRequirement{testRequirement(testSkillDocker, true, "", 0)}},
+	}
+
+	ids := []int{}
+	for _, match := range testScorer.MatchJobs(cv, jobs) {
+		ids = append(ids, match.Id)
+	}
+
+	// Job 1 is below the threshold, job 4 asks for a skill the CV lacks
+	if !reflect.DeepEqual(ids, []int{2, 3}) {
+		t.Errorf("matched jobs are %v, expected [2 3]", ids)
+	}
+}
+
+func TestAnalyzeGap(t *testing.T) {
+	job := Job{Id: 7, RoleId: 1, Role: JobRole{Id: 1, Name: "Backend"}, Yoe: 3, Skills: []JobSkillRequirement{
+		testRequirement(testSkillGo, true, "", 0),
+		testRequirement(testSkillDocker, false, SKILL_ADVANCED, 0),
+	}}
+
+	cv := CurriculumVitae{Gpa: 3, JobRoleId: 2, JobRole: JobRole{Id: 2, Name: "Frontend"}, Yoe: 1, Skills: []CVSkill{
+		testCVSkill(testSkillDocker, SKILL_BEGINNER, 1),
+	}}
+
+	gap := testScorer.AnalyzeGap(cv, job)
+
+	if gap.JobId != 7 || gap.Score != 6.5 || gap.Threshold != 15 || gap.IsMatch || !gap.Disqualified {
+		t.Errorf("gap is %+v, expected job 7, score 6.5, threshold 15, disqualified and not a match", gap)
+	}
+
+	expectedSkills := []struct {
+		skillId       int
+		isMissing     bool
+		scoreIfClosed float64
+	}{
+		{testSkillGo.Id, true, 9.5},
+		{testSkillDocker.Id, false, 8},
+	}
+
+	if len(gap.MissingSkills) != len(expectedSkills) {
+		t.Fatalf("%d skill gaps, expected %d", len(gap.MissingSkills), len(expectedSkills))
+	}
+
+	for i, expected := range expectedSkills {
+		skillGap := gap.MissingSkills[i]
+
+		if skillGap.Skill.Id != expected.skillId || skillGap.IsMissing != expected.isMissing || skillGap.ScoreIfClosed != expected.scoreIfClosed {
+			t.Errorf("skill gap %d is %+v, expected %+v", i, skillGap, expected)
+		}
+	}
+
+	if gap.Yoe == nil || *gap.Yoe != (YoeGap{Required: 3, Actual: 1, Shortfall: 2, ScoreIfClosed: 6.5}) {
+		t.Errorf("experience gap is %+v, expected 2 years short with a score of 6.5 once closed", gap.Yoe)
+	}
+
+	if gap.Role == nil || gap.Role.Required.Id != 1 || gap.Role.Actual.Id != 2 || gap.Role.ScoreIfClosed != 21.5 {
+		t.Errorf("role gap is %+v, expected role 1 instead of 2 with a score of 21.5 once closed", gap.Role)
+	}
+
+	if gap.ScoreIfAllClosed != 36 {
+		t.Errorf("score once every gap is closed is %g, expected 36", gap.ScoreIfAllClosed)
+	}
+
+	// Gaps are closed on copies, the CV itself is left untouched
+	if len(cv.Skills) != 1 || cv.Skills[0].Level != SKILL_BEGINNER || cv.Yoe != 1 || cv.JobRoleId != 2 {
+		t.Errorf("CV was changed by the gap analysis: %+v", cv)
+	}
+}
+
+func TestAnalyzeGapWithoutGap(t *testing.T) {
+	job := Job{Id: 7, RoleId: 1, Yoe: 3, Skills: []JobSkillRequirement{testRequirement(testSkillGo, true, SKILL_INTERMEDIATE, 1)}}
+	cv := CurriculumVitae{Gpa: 3, JobRoleId: 1, Yoe: 3, Skills: []CVSkill{testCVSkill(testSkillGo, SKILL_EXPERT, 4)}}
+
+	gap := testScorer.AnalyzeGap(cv, job)
+
+	if !gap.IsMatch || gap.Disqualified || gap.Score != 33 || gap.ScoreIfAllClosed != gap.Score {
+		t.Errorf("gap is %+v, expected a match scoring 33 with nothing left to close", gap)
+	}
+
+	if len(gap.MissingSkills) != 0 || gap.Yoe != nil || gap.Role != nil {
+		t.Errorf("gap is %+v, expected no skill, experience nor role gap", gap)
+	}
+}
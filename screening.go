@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ================================== Screening Questions =========================================
+// ================================================================================================
+// ================================================================================================
+//
+// An employer asks graduates an ordered list of questions when they apply to a job.
+// A knock-out question rejects the application as soon as it is submitted :
+//   - yes/no and multiple choice questions, when the answer is one of 'reject_answers'
+//   - numeric questions, when the answer is below 'min_value'
+
+const (
+	QUESTION_TEXT   string = "text"
+	QUESTION_YES_NO string = "yes_no"
+	QUESTION_CHOICE string = "choice"
+	QUESTION_NUMBER string = "number"
+)
+
+const (
+	maxScreeningQuestions    int = 20
+	maxScreeningAnswerLength int = 5000
+	maxCoverLetterLength     int = 10000
+)
+
+type ScreeningQuestion struct {
+	Id            int      `json:"id"`
+	JobId         int      `json:"job_id" gorm:"index"`
+	Position      int      `json:"position"`
+	Type          string   `json:"type"`
+	Prompt        string   `json:"prompt"`
+	Choices       []string `json:"choices" gorm:"serializer:json"`
+	Required      bool     `json:"required"`
+	RejectAnswers []string `json:"reject_answers,omitempty" gorm:"serializer:json"`
+	MinValue      *float64 `json:"min_value,omitempty"`
+}
+
+type ScreeningAnswer struct {
+	Id            int    `json:"id"`
+	ApplicationId int    `json:"application_id" gorm:"index"`
+	QuestionId    int    `json:"question_id"`
+	Answer        string `json:"answer"`
+}
+
+func (q ScreeningQuestion) isValid() error {
+	if strings.TrimSpace(q.Prompt) == "" {
+		return fmt.Errorf("Screening question prompt is mandatory")
+	}
+
+	switch q.Type {
+	case QUESTION_TEXT:
+		if len(q.RejectAnswers) > 0 || q.MinValue != nil {
+			return fmt.Errorf("Free text question '%s' can't be a knock-out question", q.Prompt)
+		}
+
+	case QUESTION_YES_NO:
+		for _, answer := range q.RejectAnswers {
+			if answer != "yes" && answer != "no" {
+				return fmt.Errorf("Knock-out answer '%s' of question '%s' must be 'yes' or 'no'", answer, q.Prompt)
+			}
+		}
+
+	case QUESTION_CHOICE:
+		if len(q.Choices) < 2 {
+			return fmt.Errorf("Multiple choice question '%s' needs at least 2 choices", q.Prompt)
+		}
+
+		for _, answer := range q.RejectAnswers {
+			if !containsString(q.Choices, answer) {
+				return fmt.Errorf("Knock-out answer '%s' of question '%s' isn't one of its choices", answer, q.Prompt)
+			}
+		}
+
+	case QUESTION_NUMBER:
+		if len(q.RejectAnswers) > 0 {
+			return fmt.Errorf("Numeric question '%s' knocks out with 'min_value', not 'reject_answers'", q.Prompt)
+		}
+
+	default:
+		return fmt.Errorf("Unknown question type '%s', expected one of: text, yes_no, choice, number", q.Type)
+	}
+
+	if q.MinValue != nil && q.Type != QUESTION_NUMBER {
+		return fmt.Errorf("Only numeric questions can have a 'min_value'")
+	}
+
+	return nil
+}
+
+func validateScreeningQuestions(questions []ScreeningQuestion) error {
+	if len(questions) > maxScreeningQuestions {
+		return fmt.Errorf("A job can't have more than %d screening questions", maxScreeningQuestions)
+	}
+
+	for _, question := range questions {
+		if err := question.isValid(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Questions are kept in the order they were sent
+func normalizeScreeningQuestions(questions []ScreeningQuestion) {
+	for i := range questions {
+		questions[i].Id = 0
+		questions[i].JobId = 0
+		questions[i].Position = i + 1
+	}
+}
+
+// Knock-out criteria are only shown to the employers, graduates would otherwise know which answers to avoid
+func hideKnockOutCriteria(jobs []Job) {
+	for i := range jobs {
+		for j := range jobs[i].Questions {
+			jobs[i].Questions[j].RejectAnswers = nil
+			jobs[i].Questions[j].MinValue = nil
+		}
+	}
+}
+
+func preloadScreeningQuestions(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
+
+func getScreeningQuestions(jobId int) []ScreeningQuestion {
+	questions := []ScreeningQuestion{}
+	gormDB.Where("job_id = ?", jobId).Order("position").Find(&questions)
+
+	return questions
+}
+
+// Answers given by graduates who already applied refer to the current questions, they can't be changed anymore
+func replaceScreeningQuestions(tx *gorm.DB, jobId int, questions []ScreeningQuestion) error {
+	var applicationCount int64
+	tx.Model(&JobApplication{}).Where("job_id = ?", jobId).Count(&applicationCount)
+
+	if applicationCount > 0 {
+		return fmt.Errorf("Screening questions can't be changed once the job received applications")
+	}
+
+	if err := tx.Where("job_id = ?", jobId).Delete(&ScreeningQuestion{}).Error; err != nil {
+		return err
+	}
+
+	if len(questions) == 0 {
+		return nil
+	}
+
+	normalizeScreeningQuestions(questions)
+
+	for i := range questions {
+		questions[i].JobId = jobId
+	}
+
+	return tx.Create(&questions).Error
+}
+
+func validateScreeningAnswers(questions []ScreeningQuestion, answers []ScreeningAnswer) error {
+	answerByQuestion := map[int]string{}
+
+	for _, answer := range answers {
+		if _, ok := answerByQuestion[answer.QuestionId]; ok {
+			return fmt.Errorf("Question %d is answered more than once", answer.QuestionId)
+		}
+
+		answerByQuestion[answer.QuestionId] = strings.TrimSpace(answer.Answer)
+	}
+
+	for _, question := range questions {
+		answer, ok := answerByQuestion[question.Id]
+		delete(answerByQuestion, question.Id)
+
+		if !ok || answer == "" {
+			if question.Required {
+				return fmt.Errorf("Question '%s' is mandatory", question.Prompt)
+			}
+
+			continue
+		}
+
+		switch question.Type {
+		case QUESTION_TEXT:
+			if len(answer) > maxScreeningAnswerLength {
+				return fmt.Errorf("Answer to '%s' can't be longer than %d characters", question.Prompt, maxScreeningAnswerLength)
+			}
+
+		case QUESTION_YES_NO:
+			if answer != "yes" && answer != "no" {
+				return fmt.Errorf("Answer to '%s' must be 'yes' or 'no'", question.Prompt)
+			}
+
+		case QUESTION_CHOICE:
+			if !containsString(question.Choices, answer) {
+				return fmt.Errorf("Answer to '%s' must be one of: %s", question.Prompt, strings.Join(question.Choices, ", "))
+			}
+
+		case QUESTION_NUMBER:
+			if _, err := strconv.ParseFloat(answer, 64); err != nil {
+				return fmt.Errorf("Answer to '%s' must be a number", question.Prompt)
+			}
+		}
+	}
+
+	for questionId := range answerByQuestion {
+		return fmt.Errorf("Question %d isn't asked by this job", questionId)
+	}
+
+	return nil
+}
+
+// Return the question that knocks the application out, if any. Answers must have been validated first
+func findKnockOutQuestion(questions []ScreeningQuestion, answers []ScreeningAnswer) (ScreeningQuestion, bool) {
+	answerByQuestion := map[int]string{}
+
+	for _, answer := range answers {
+		answerByQuestion[answer.QuestionId] = strings.TrimSpace(answer.Answer)
+	}
+
+	for _, question := range questions {
+		answer, ok := answerByQuestion[question.Id]
+		if !ok || answer == "" {
+			continue
+		}
+
+		if containsString(question.RejectAnswers, answer) {
+			return question, true
+		}
+
+		if question.Type == QUESTION_NUMBER && question.MinValue != nil {
+			value, err := strconv.ParseFloat(answer, 64)
+			if err == nil && value < *question.MinValue {
+				return question, true
+			}
+		}
+	}
+
+	return ScreeningQuestion{}, false
+}
+
+// Reject a freshly submitted application failing a knock-out question. The change is made by the system (no user)
+func applyKnockOutQuestions(application JobApplication) JobApplication {
+	question, isKnockedOut := findKnockOutQuestion(getScreeningQuestions(application.JobId), application.Answers)
+	if !isKnockedOut {
+		return application
+	}
+
+	note := "Automatically rejected, the answer to '" + question.Prompt + "' doesn't meet the job requirements"
+
+	rejected, err := moveApplication(application, APPLICATION_REJECTED, note, 0)
+	if err != nil {
+		fmt.Println("[Screening] unable to reject application ", application.Id, ": ", err.Error())
+		return application
+	}
+
+	return rejected
+}
+
+func containsString(list []string, value string) bool {
+	for _, element := range list {
+		if element == value {
+			return true
+		}
+	}
+
+	return false
+}
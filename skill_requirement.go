@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ==================================== Skill Requirements ========================================
+// ================================================================================================
+// ================================================================================================
+//
+// A job lists the skills it asks for, each one required or nice-to-have, with an optional
+// proficiency level and years of experience. A CV lists the skills of the graduate the same way.
+// Both are stored on the rows of the 'job_skills_tree' and 'graduate_skills_tree' tables,
+// the 'tree' of jobs and CVs still lists the bare skills.
+//
+// Matching :
+//   - a required skill missing from the CV disqualifies the graduate, whatever the score
+//   - a skill below the expected level or years of experience earns half the points
+
+const (
+	SKILL_BEGINNER     string = "beginner"
+	SKILL_INTERMEDIATE string = "intermediate"
+	SKILL_ADVANCED     string = "advanced"
+	SKILL_EXPERT       string = "expert"
+)
+
+var skillLevelRanks map[string]int = map[string]int{
+	SKILL_BEGINNER:     1,
+	SKILL_INTERMEDIATE: 2,
+	SKILL_ADVANCED:     3,
+	SKILL_EXPERT:       4,
+}
+
+type JobSkillRequirement struct {
+	JobId      int      `json:"job_id" gorm:"primaryKey;autoIncrement:false"`
+	JobSkillId int      `json:"job_skill_id" gorm:"primaryKey;autoIncrement:false"`
+	Required   bool     `json:"required" gorm:"default:false"`
+	Level      string   `json:"level"` // Empty when any level will do
+	Years      float64  `json:"years"`
+	Skill      JobSkill `json:"skill" gorm:"foreignKey:JobSkillId"`
+}
+
+func (JobSkillRequirement) TableName() string {
+	return "job_skills_tree"
+}
+
+type CVSkill struct {
+	CurriculumVitaeId int      `json:"cv_id" gorm:"primaryKey;autoIncrement:false"`
+	JobSkillId        int      `json:"job_skill_id" gorm:"primaryKey;autoIncrement:false"`
+	Level             string   `json:"level"`
+	Years             float64  `json:"years"`
+	Skill             JobSkill `json:"skill" gorm:"foreignKey:JobSkillId"`
+}
+
+func (CVSkill) TableName() string {
+	return "graduate_skills_tree"
+}
+
+func validateSkillLevel(level string, years float64) error {
+	if _, ok := skillLevelRanks[level]; level != "" && !ok {
+		return fmt.Errorf("Unknown skill level '%s', expected one of: beginner, intermediate, advanced, expert", level)
+	}
+
+	if years < 0 {
+		return fmt.Errorf("Years of experience with a skill can't be negative")
+	}
+
+	return nil
+}
+
+func (r JobSkillRequirement) isValid() error {
+	if r.JobId <= 0 || r.JobSkillId <= 0 {
+		return fmt.Errorf("Job and skill are mandatory")
+	}
+
+	return validateSkillLevel(r.Level, r.Years)
+}
+
+func (s CVSkill) isValid() error {
+	if s.CurriculumVitaeId <= 0 || s.JobSkillId <= 0 {
+		return fmt.Errorf("CV and skill are mandatory")
+	}
+
+	return validateSkillLevel(s.Level, s.Years)
+}
+
+// Whether the graduate skill reaches the level and years of experience the job expects
+func (r JobSkillRequirement) isMetBy(skill CVSkill) bool {
+	if r.Level != "" && skillLevelRanks[skill.Level] < skillLevelRanks[r.Level] {
+		return false
+	}
+
+	return skill.Years >= r.Years
+}
+
+func findCVSkill(skills []CVSkill, skillId int) (CVSkill, bool) {
+	for _, skill := range skills {
+		if skill.JobSkillId == skillId {
+			return skill, true
+		}
+	}
+
+	return CVSkill{}, false
+}
+
+func preloadSkills(db *gorm.DB) *gorm.DB {
+	return db.Preload("Tree").Preload("Skills.Skill")
+}
+
+func findJobSkillById(skillId int) (JobSkill, error) {
+	skill := JobSkill{}
+
+	err := gormDB.Where("id = ?", skillId).First(&skill).Error
+	if err != nil {
+		return skill, fmt.Errorf("Skill %d not found in the system", skillId)
+	}
+
+	return skill, nil
+}
+
+// Adding a skill already listed updates its requirement
+func saveJobSkillRequirement(requirement *JobSkillRequirement) error {
+	if err := requirement.isValid(); err != nil {
+		return err
+	}
+
+	skill, err := findJobSkillById(requirement.JobSkillId)
+	if err != nil {
+		return err
+	}
+
+	err = gormDB.
+		Omit("Skill").
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(requirement).Error
+
+	requirement.Skill = skill
+	return err
+}
+
+func saveCVSkill(skill *CVSkill) error {
+	if err := skill.isValid(); err != nil {
+		return err
+	}
+
+	jobSkill, err := findJobSkillById(skill.JobSkillId)
+	if err != nil {
+		return err
+	}
+
+	err = gormDB.
+		Omit("Skill").
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(skill).Error
+
+	skill.Skill = jobSkill
+	return err
+}
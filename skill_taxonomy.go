@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ================================================================================================
+// ================================================================================================
+// ===================================== Skill Taxonomy ===========================================
+// ================================================================================================
+// ================================================================================================
+//
+// Skills are organised as a tree ("Docker" under "DevOps") and can be known under other names,
+// their aliases ("CSharp" for "C#"). Names are compared without case nor extra spaces, creating
+// a skill whose name or alias is already known returns the existing skill.
+// Duplicates created before are merged by an admin : jobs, CVs, children and aliases move to the kept skill.
+//
+// In matching, a CV skill related to a job skill (its parent, one of its children or a sibling)
+// earns partial credit when the job skill itself is missing.
+
+type SkillAlias struct {
+	Id             int    `json:"id"`
+	SkillId        int    `json:"skill_id" gorm:"index"`
+	Name           string `json:"name"`
+	NormalizedName string `json:"-" gorm:"uniqueIndex"`
+}
+
+type SkillRequest struct {
+	Name     string   `json:"name"`
+	ParentId *int     `json:"parent_id"`
+	Aliases  []string `json:"aliases"`
+}
+
+func normalizeSkillName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// Find the skill known under 'name', either its own name or one of its aliases
+func findSkillByName(tx *gorm.DB, name string) (JobSkill, bool) {
+	normalized := normalizeSkillName(name)
+	skills := []JobSkill{}
+
+	tx.Where("normalized_name = ?", normalized).Limit(1).Find(&skills)
+	if len(skills) > 0 {
+		return skills[0], true
+	}
+
+	aliases := []SkillAlias{}
+	tx.Where("normalized_name = ?", normalized).Limit(1).Find(&aliases)
+	if len(aliases) > 0 {
+		tx.Where("id = ?", aliases[0].SkillId).Limit(1).Find(&skills)
+	}
+
+	if len(skills) > 0 {
+		return skills[0], true
+	}
+
+	return JobSkill{}, false
+}
+
+func findSkillWithAliases(skillId int) (JobSkill, error) {
+	skill := JobSkill{}
+
+	err := gormDB.Preload("Aliases").Where("id = ?", skillId).First(&skill).Error
+	if err != nil {
+		return skill, fmt.Errorf("Skill %d not found in the system", skillId)
+	}
+
+	return skill, nil
+}
+
+// Parent, grand-parent, ... of the skill, closest first. Stops on a loop left in the tree by older data
+func getSkillAncestorIds(tx *gorm.DB, skillId int) ([]int, error) {
+	ancestorIds := []int{}
+	visited := map[int]bool{skillId: true}
+
+	for currentId := skillId; ; {
+		current := JobSkill{}
+		if err := tx.Where("id = ?", currentId).First(&current).Error; err != nil {
+			return ancestorIds, fmt.Errorf("Parent skill %d not found in the system", currentId)
+		}
+
+		if current.ParentId == 0 || visited[current.ParentId] {
+			return ancestorIds, nil
+		}
+
+		visited[current.ParentId] = true
+		ancestorIds = append(ancestorIds, current.ParentId)
+		currentId = current.ParentId
+	}
+}
+
+func isSkillAncestor(tx *gorm.DB, ancestorId int, skillId int) (bool, error) {
+	ancestorIds, err := getSkillAncestorIds(tx, skillId)
+	if err != nil {
+		return false, err
+	}
+
+	for _, id := range ancestorIds {
+		if id == ancestorId {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// A skill can't be moved under itself or one of its descendants
+func validateSkillParent(tx *gorm.DB, skillId int, parentId int) error {
+	if parentId == 0 {
+		return nil
+	}
+
+	if parentId == skillId {
+		return fmt.Errorf("A skill can't be placed under itself or one of its children")
+	}
+
+	isDescendant, err := isSkillAncestor(tx, skillId, parentId)
+	if err != nil {
+		return err
+	}
+
+	if isDescendant {
+		return fmt.Errorf("A skill can't be placed under itself or one of its children")
+	}
+
+	return nil
+}
+
+func addSkillAliases(tx *gorm.DB, skill JobSkill, names []string) error {
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+
+		existing, ok := findSkillByName(tx, name)
+		if ok && existing.Id == skill.Id {
+			continue
+		}
+
+		if ok {
+			return fmt.Errorf("'%s' already names the skill '%s'", name, existing.Name)
+		}
+
+		alias := SkillAlias{SkillId: skill.Id, Name: strings.TrimSpace(name), NormalizedName: normalizeSkillName(name)}
+		if err := tx.Create(&alias).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Return the existing skill when the name (or an alias) is already known
+func createSkill(request SkillRequest) (JobSkill, error) {
+	skill := JobSkill{}
+
+	name := strings.Join(strings.Fields(request.Name), " ")
+	if name == "" {
+		return skill, fmt.Errorf("Skill name is mandatory")
+	}
+
+	err := gormDB.Transaction(func(tx *gorm.DB) error {
+		if existing, ok := findSkillByName(tx, name); ok {
+			skill = existing
+			return nil
+		}
+
+		skill = JobSkill{Name: name, NormalizedName: normalizeSkillName(name)}
+
+		if request.ParentId != nil {
+			if err := validateSkillParent(tx, 0, *request.ParentId); err != nil {
+				return err
+			}
+
+			skill.ParentId = *request.ParentId
+		}
+
+		if err := tx.Create(&skill).Error; err != nil {
+			return err
+		}
+
+		return addSkillAliases(tx, skill, request.Aliases)
+	})
+	if err != nil {
+		return skill, err
+	}
+
+	return findSkillWithAliases(skill.Id)
+}
+
+// Move the skill under another one (0 for the top level) and add aliases. The name itself isn't changed,
+// jobs and CVs refer to it
+func updateSkill(skillId int, request SkillRequest) (JobSkill, error) {
+	skill, err := findSkillWithAliases(skillId)
+	if err != nil {
+		return skill, err
+	}
+
+	err = gormDB.Transaction(func(tx *gorm.DB) error {
+		if request.ParentId != nil {
+			if err := validateSkillParent(tx, skill.Id, *request.ParentId); err != nil {
+				return err
+			}
+
+			if err := tx.Model(&JobSkill{Id: skill.Id}).Update("parent_id", *request.ParentId).Error; err != nil {
+				return err
+			}
+		}
+
+		return addSkillAliases(tx, skill, request.Aliases)
+	})
+	if err != nil {
+		return skill, err
+	}
+
+	return findSkillWithAliases(skill.Id)
+}
+
+// Merge the 'duplicate' skill into 'skillId'. Where a job or a CV lists both, the kept skill row stays
+// and only becomes required if either was. The duplicate name becomes an alias of the kept skill
+func mergeSkills(skillId int, duplicateId int) (JobSkill, error) {
+	if skillId == duplicateId {
+		return JobSkill{}, fmt.Errorf("A skill can't be merged into itself")
+	}
+
+	skill, err := findSkillWithAliases(skillId)
+	if err != nil {
+		return skill, err
+	}
+
+	duplicate, err := findSkillWithAliases(duplicateId)
+	if err != nil {
+		return skill, err
+	}
+
+	err = gormDB.Transaction(func(tx *gorm.DB) error {
+		// The kept skill was below the duplicate, it takes its place in the tree before the duplicate children
+		// move under it. Otherwise the skill between them would end up both parent and child of the kept skill
+		isBelowDuplicate, err := isSkillAncestor(tx, duplicate.Id, skill.Id)
+		if err != nil {
+			return err
+		}
+
+		if isBelowDuplicate {
+			if err := tx.Model(&JobSkill{Id: skill.Id}).Update("parent_id", duplicate.ParentId).Error; err != nil {
+				return err
+			}
+		}
+
+		statements := []struct {
+			query string
+			args  []interface{}
+		}{
+			{`UPDATE job_skills_tree SET required = true
+				WHERE job_skill_id = ? AND job_id IN (SELECT job_id FROM job_skills_tree WHERE job_skill_id = ? AND required)`, []interface{}{skill.Id, duplicate.Id}},
+			{"UPDATE OR IGNORE job_skills_tree SET job_skill_id = ? WHERE job_skill_id = ?", []interface{}{skill.Id, duplicate.Id}},
+			{"DELETE FROM job_skills_tree WHERE job_skill_id = ?", []interface{}{duplicate.Id}},
+			{"UPDATE OR IGNORE graduate_skills_tree SET job_skill_id = ? WHERE job_skill_id = ?", []interface{}{skill.Id, duplicate.Id}},
+			{"DELETE FROM graduate_skills_tree WHERE job_skill_id = ?", []interface{}{duplicate.Id}},
+			{"UPDATE job_skills SET parent_id = ? WHERE parent_id = ? AND id <> ?", []interface{}{skill.Id, duplicate.Id, skill.Id}},
+			{"UPDATE skill_aliases SET skill_id = ? WHERE skill_id = ?", []interface{}{skill.Id, duplicate.Id}},
+			{"DELETE FROM job_skills WHERE id = ?", []interface{}{duplicate.Id}},
+		}
+
+		for _, stmt := range statements {
+			if err := tx.Exec(stmt.query, stmt.args...).Error; err != nil {
+				return err
+			}
+		}
+
+		return addSkillAliases(tx, skill, []string{duplicate.Name})
+	})
+	if err != nil {
+		return skill, err
+	}
+
+	return findSkillWithAliases(skill.Id)
+}
+
+// Whether the two skills are parent and child, or share the same parent
+func areSkillsRelated(first JobSkill, second JobSkill) bool {
+	if first.Id == second.Id {
+		return false
+	}
+
+	return first.ParentId == second.Id ||
+		second.ParentId == first.Id ||
+		(first.ParentId != 0 && first.ParentId == second.ParentId)
+}
+
+func findRelatedCVSkill(skills []CVSkill, skill JobSkill) (CVSkill, bool) {
+	for _, cvSkill := range skills {
+		if areSkillsRelated(cvSkill.Skill, skill) {
+			return cvSkill, true
+		}
+	}
+
+	return CVSkill{}, false
+}
+
+// Skills created before the taxonomy existed get their normalized name
+func migrateSkillNormalizedNames(db *gorm.DB) error {
+	skills := []JobSkill{}
+	if err := db.Where("normalized_name IS NULL OR normalized_name = ''").Find(&skills).Error; err != nil {
+		return err
+	}
+
+	for _, skill := range skills {
+		err := db.Model(&JobSkill{Id: skill.Id}).Update("normalized_name", normalizeSkillName(skill.Name)).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Point gormDB to an empty database with the skill tables, for the duration of the test
+func setupSkillTestDB(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "jobs.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("unable to open the test database: %s", err.Error())
+	}
+
+	err = db.AutoMigrate(&JobSkill{}, &SkillAlias{}, &JobSkillRequirement{}, &CVSkill{})
+	if err != nil {
+		t.Fatalf("unable to migrate the test database: %s", err.Error())
+	}
+
+	previous := gormDB
+	gormDB = db
+
+	t.Cleanup(func() {
+		gormDB = previous
+	})
+}
+
+func createTestSkills(t *testing.T, skills ...JobSkill) {
+	for _, skill := range skills {
+		skill.NormalizedName = normalizeSkillName(skill.Name)
+
+		if err := gormDB.Create(&skill).Error; err != nil {
+			t.Fatalf("unable to create skill %s: %s", skill.Name, err.Error())
+		}
+	}
+}
+
+func getTestSkillParent(t *testing.T, skillId int) int {
+	skill := JobSkill{}
+	if err := gormDB.Where("id = ?", skillId).First(&skill).Error; err != nil {
+		t.Fatalf("skill %d not found: %s", skillId, err.Error())
+	}
+
+	return skill.ParentId
+}
+
+func TestValidateSkillParent(t *testing.T) {
+	setupSkillTestDB(t)
+
+	// DevOps > Docker > Compose, Rust at the top level, and a loop (6 > 5 > 6) left by older data
+	createTestSkills(t,
+		JobSkill{Id: 1, Name: "DevOps"},
+		JobSkill{Id: 2, Name: "Docker", ParentId: 1},
+		JobSkill{Id: 3, Name: "Compose", ParentId: 2},
+		JobSkill{Id: 4, Name: "Rust"},
+		JobSkill{Id: 5, Name: "Go", ParentId: 6},
+		JobSkill{Id: 6, Name: "Golang", ParentId: 5},
+	)
+
+	tests := []struct {
+		name      string
+		skillId   int
+		parentId  int
+		expectErr bool
+	}{
+		{"move to the top level", 2, 0, false},
+		{"move under another branch", 3, 4, false},
+		{"new skill under an existing one", 0, 3, false},
+		{"under itself", 2, 2, true},
+		{"under its child", 1, 2, true},
+		{"under its grand-child", 1, 3, true},
+		{"unknown parent", 4, 99, true},
+		{"under a loop", 4, 5, false},
+		{"inside a loop", 5, 6, true},
+	}
+
+	for _, test := range tests {
+		err := validateSkillParent(gormDB, test.skillId, test.parentId)
+
+		if test.expectErr && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+
+		if !test.expectErr && err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err.Error())
+		}
+	}
+}
+
+func TestMergeSkills(t *testing.T) {
+	tests := []struct {
+		name     string
+		skills   []JobSkill
+		keptId   int
+		mergedId int
+		parents  map[int]int // Expected parent of each remaining skill
+	}{
+		{
+			name:     "duplicate is the parent",
+			skills:   []JobSkill{{Id: 1, Name: "Tools"}, {Id: 2, Name: "Containers", ParentId: 1}, {Id: 3, Name: "Docker", ParentId: 2}, {Id: 4, Name: "Podman", ParentId: 2}},
+			keptId:   3,
+			mergedId: 2,
+			parents:  map[int]int{1: 0, 3: 1, 4: 3},
+		},
+		{
+			name:     "duplicate is the grand-parent",
+			skills:   []JobSkill{{Id: 1, Name: "DevOps"}, {Id: 2, Name: "Containers", ParentId: 1}, {Id: 3, Name: "Docker", ParentId: 2}},
+			keptId:   3,
+			mergedId: 1,
+			parents:  map[int]int{3: 0, 2: 3},
+		},
+		{
+			name:     "duplicate is a child",
+			skills:   []JobSkill{{Id: 1, Name: "JavaScript"}, {Id: 2, Name: "JS", ParentId: 1}, {Id: 3, Name: "Node", ParentId: 2}},
+			keptId:   1,
+			mergedId: 2,
+			parents:  map[int]int{1: 0, 3: 1},
+		},
+		{
+			name:     "unrelated skills",
+			skills:   []JobSkill{{Id: 1, Name: "Languages"}, {Id: 2, Name: "C#", ParentId: 1}, {Id: 3, Name: "CSharp"}, {Id: 4, Name: ".NET", ParentId: 3}},
+			keptId:   2,
+			mergedId: 3,
+			parents:  map[int]int{1: 0, 2: 1, 4: 2},
+		},
+	}
+
+	for _, test := range tests {
+		setupSkillTestDB(t)
+		createTestSkills(t, test.skills...)
+
+		merged, err := mergeSkills(test.keptId, test.mergedId)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err.Error())
+			continue
+		}
+
+		for skillId, parentId := range test.parents {
+			if got := getTestSkillParent(t, skillId); got != parentId {
+				t.Errorf("%s: skill %d is under %d, expected %d", test.name, skillId, got, parentId)
+			}
+
+			if err := validateSkillParent(gormDB, skillId, getTestSkillParent(t, skillId)); err != nil {
+				t.Errorf("%s: skill %d is left in a loop: %s", test.name, skillId, err.Error())
+			}
+		}
+
+		var count int64
+		gormDB.Model(&JobSkill{}).Where("id = ?", test.mergedId).Count(&count)
+		if count != 0 {
+			t.Errorf("%s: merged skill %d still exists", test.name, test.mergedId)
+		}
+
+		if kept, ok := findSkillByName(gormDB, test.skills[test.mergedId-1].Name); !ok || kept.Id != merged.Id {
+			t.Errorf("%s: merged skill name doesn't lead to the kept skill", test.name)
+		}
+	}
+}
+
+func TestMergeSkillsRequirements(t *testing.T) {
+	setupSkillTestDB(t)
+	createTestSkills(t, JobSkill{Id: 1, Name: "Kubernetes"}, JobSkill{Id: 2, Name: "K8s"})
+
+	requirements := []JobSkillRequirement{
+		{JobId: 1, JobSkillId: 1, Required: false},
+		{JobId: 1, JobSkillId: 2, Required: true},
+		{JobId: 2, JobSkillId: 2, Required: false, Level: SKILL_ADVANCED},
+	}
+	if err := gormDB.Omit("Skill").Create(&requirements).Error; err != nil {
+		t.Fatalf("unable to create the requirements: %s", err.Error())
+	}
+
+	if err := gormDB.Omit("Skill").Create(&CVSkill{CurriculumVitaeId: 1, JobSkillId: 2}).Error; err != nil {
+		t.Fatalf("unable to create the CV skill: %s", err.Error())
+	}
+
+	if _, err := mergeSkills(1, 2); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	merged := []JobSkillRequirement{}
+	gormDB.Order("job_id").Find(&merged)
+
+	expected := []JobSkillRequirement{
+		{JobId: 1, JobSkillId: 1, Required: true},
+		{JobId: 2, JobSkillId: 1, Required: false, Level: SKILL_ADVANCED},
+	}
+
+	if len(merged) != len(expected) {
+		t.Fatalf("%d requirements left, expected %d", len(merged), len(expected))
+	}
+
+	for i := range expected {
+		got := merged[i]
+		if got.JobId != expected[i].JobId || got.JobSkillId != expected[i].JobSkillId || got.Required != expected[i].Required || got.Level != expected[i].Level {
+			t.Errorf("requirement %d is %+v, expected %+v", i, got, expected[i])
+		}
+	}
+
+	cvSkills := []CVSkill{}
+	gormDB.Find(&cvSkills)
+	if len(cvSkills) != 1 || cvSkills[0].JobSkillId != 1 {
+		t.Errorf("CV skills are %+v, expected the kept skill only", cvSkills)
+	}
+}